@@ -74,6 +74,12 @@ func (d Dir) Link(srcDir Dir, src, dst string, flag int) error {
 	return d.link(srcDir, src, dst, flag)
 }
 
+// Rename renames src in srcDir to dst in d. If dst already exists, it is
+// replaced.
+func (d Dir) Rename(srcDir Dir, src, dst string) error {
+	return d.rename(srcDir, src, dst)
+}
+
 func (d Dir) Symlink(src, dst string) error {
 	return d.symlink(src, dst)
 }
@@ -102,3 +108,16 @@ func (d Dir) Utimes(path string, atime, mtime time.Time, flag int) error {
 func Futimes(f *os.File, atime, mtime time.Time) error {
 	return futimes(f, atime, mtime)
 }
+
+// Setxattr sets the extended attribute name of path to value. Attribute names
+// include a namespace prefix, as on Linux (e.g. "user.xdg.origin.url"). On
+// systems without namespaces, the "user." prefix is removed.
+func (d Dir) Setxattr(path, name string, value []byte) error {
+	return d.setxattr(path, name, value)
+}
+
+// Fsetxattr is like Setxattr, but sets the extended attribute of an open
+// file.
+func Fsetxattr(f *os.File, name string, value []byte) error {
+	return fsetxattr(f, name, value)
+}
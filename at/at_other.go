@@ -91,6 +91,10 @@ func (d Dir) link(srcDir Dir, src, dst string, flag int) error {
 	return os.Link(srcDir.join(src), d.join(dst))
 }
 
+func (d Dir) rename(srcDir Dir, src, dst string) error {
+	return os.Rename(srcDir.join(src), d.join(dst))
+}
+
 func (d Dir) symlink(src, dst string) error {
 	return os.Symlink(src, d.join(dst))
 }
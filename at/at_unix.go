@@ -128,6 +128,13 @@ func (d Dir) link(srcDir Dir, src, dst string, flag int) error {
 	return nil
 }
 
+func (d Dir) rename(srcDir Dir, src, dst string) error {
+	if err := unix.Renameat(int(srcDir), src, int(d), dst); err != nil {
+		return &os.LinkError{Op: "rename", Old: src, New: dst, Err: err}
+	}
+	return nil
+}
+
 func (d Dir) symlink(src, dst string) error {
 	if err := unix.Symlinkat(src, int(d), dst); err != nil {
 		return &os.LinkError{Op: "symlink", Old: src, New: dst, Err: err}
@@ -0,0 +1,114 @@
+// Copyright (c) 2026 Tim van der Molen <tim@kariliq.nl>
+//
+// Permission to use, copy, modify, and distribute this software for any
+// purpose with or without fee is hereby granted, provided that the above
+// copyright notice and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+// WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+// ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+// WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+// ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+// OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+package main
+
+import (
+	"io"
+	"log"
+	"os"
+
+	"github.com/tbvdm/go-openbsd"
+	"github.com/tbvdm/sigtop/cryptio"
+	"github.com/tbvdm/sigtop/getopt"
+)
+
+var cmdDecryptExportEntry = cmdEntry{
+	name:  "decrypt-export",
+	alias: "",
+	usage: "-e passfile [file [outfile]]",
+	exec:  cmdDecryptExport,
+}
+
+func cmdDecryptExport(args []string) cmdStatus {
+	getopt.ParseArgs("e:", args)
+	var eArg getopt.Arg
+	for getopt.Next() {
+		switch opt := getopt.Option(); opt {
+		case 'e':
+			eArg = getopt.OptionArg()
+		}
+	}
+
+	if err := getopt.Err(); err != nil {
+		log.Fatal(err)
+	}
+
+	args = getopt.Args()
+	if !eArg.Set() || len(args) > 2 {
+		return cmdUsage
+	}
+
+	passphrase, err := passphraseFromArgument(eArg)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	infile := os.Stdin
+	if len(args) > 0 && args[0] != "-" {
+		if infile, err = os.Open(args[0]); err != nil {
+			log.Fatal(err)
+		}
+		defer infile.Close()
+	}
+
+	outfile := os.Stdout
+	if len(args) > 1 && args[1] != "-" {
+		if outfile, err = os.OpenFile(args[1], os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0666); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	if outfile == os.Stdout {
+		if err := openbsd.Pledge("stdio"); err != nil {
+			log.Fatal(err)
+		}
+	} else {
+		// For removing the output file on error
+		if err := openbsd.Unveil(args[1], "c"); err != nil {
+			log.Fatal(err)
+		}
+		if err := openbsd.Pledge("stdio cpath"); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	if err := decryptExport(infile, outfile, passphrase); err != nil {
+		log.Print(err)
+		// The output cannot be trusted, so remove it
+		if outfile != os.Stdout {
+			outfile.Close()
+			os.Remove(args[1])
+		}
+		return cmdError
+	}
+
+	if outfile != os.Stdout {
+		if err := outfile.Close(); err != nil {
+			log.Print(err)
+			return cmdError
+		}
+	}
+
+	return cmdOK
+}
+
+func decryptExport(r io.Reader, w io.Writer, passphrase []byte) error {
+	cr, err := cryptio.NewReader(r, passphrase)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(w, cr)
+	return err
+}
@@ -0,0 +1,393 @@
+// Copyright (c) 2026 Tim van der Molen <tim@kariliq.nl>
+//
+// Permission to use, copy, modify, and distribute this software for any
+// purpose with or without fee is hereby granted, provided that the above
+// copyright notice and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+// WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+// ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+// WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+// ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+// OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+package main
+
+import (
+	"log"
+	"path"
+
+	"github.com/tbvdm/go-openbsd"
+	"github.com/tbvdm/sigtop/getopt"
+	"github.com/tbvdm/sigtop/signal"
+)
+
+// Subdirectories of the export directory
+const (
+	exportMessagesDir    = "messages"
+	exportAttachmentsDir = "attachments"
+	exportAvatarsDir     = "avatars"
+)
+
+var cmdExportEntry = cmdEntry{
+	name:  "export",
+	usage: "[-BEiMmrX] [-A filter] [-c conversation] [-d signal-directory] [-e passfile] [-F filter] [-f format] [-k [system:]keyfile] [-N name-template] [-P period] [-R pseudonym-file] [-S sanitiser] [-s interval] [-T time] [-t time-format] [-x sidecar-format] [-z time-zone] [directory]",
+	exec:  cmdExport,
+}
+
+func cmdExport(args []string) cmdStatus {
+	var msgOpts messageExportOptions
+	var attOpts attachmentExportOptions
+	var avtOpts avatarExportOptions
+	msgOpts.format = formatText
+
+	getopt.ParseArgs("A:Bc:d:Ee:F:f:ik:MmN:P:R:rS:s:T:t:Xx:z:", args)
+	var dArg, eArg, kArg, SArg, sArg, TArg, tArg, zArg getopt.Arg
+	var AArgs, FArgs, selectors []string
+	var templateFile string
+	Bflag := false
+	iflag := false
+	rflag := false
+	for getopt.Next() {
+		switch getopt.Option() {
+		case 'A':
+			AArgs = append(AArgs, getopt.OptionArg().String())
+		case 'B':
+			Bflag = true
+		case 'c':
+			selectors = append(selectors, getopt.OptionArg().String())
+		case 'd':
+			dArg = getopt.OptionArg()
+		case 'E':
+			attOpts.strip = true
+		case 'e':
+			eArg = getopt.OptionArg()
+		case 'F':
+			FArgs = append(FArgs, getopt.OptionArg().String())
+		case 'f':
+			var err error
+			if msgOpts.format, templateFile, err = formatFromArgument(getopt.OptionArg().String()); err != nil {
+				log.Fatal(err)
+			}
+		case 'i':
+			iflag = true
+		case 'k':
+			kArg = getopt.OptionArg()
+		case 'M':
+			attOpts.mtime = mtimeSent
+		case 'm':
+			attOpts.mtime = mtimeRecv
+		case 'N':
+			var err error
+			if attOpts.nameTemplate, err = parseFilenameTemplate(getopt.OptionArg().String()); err != nil {
+				log.Fatal(err)
+			}
+		case 'P':
+			switch arg := getopt.OptionArg().String(); arg {
+			case "day":
+				msgOpts.period = periodDay
+			case "month":
+				msgOpts.period = periodMonth
+			case "year":
+				msgOpts.period = periodYear
+			default:
+				log.Fatalf("invalid period: %s", arg)
+			}
+		case 'R':
+			msgOpts.pseudonymFile = getopt.OptionArg().String()
+		case 'r':
+			rflag = true
+		case 'S':
+			SArg = getopt.OptionArg()
+		case 's':
+			sArg = getopt.OptionArg()
+		case 'T':
+			TArg = getopt.OptionArg()
+		case 't':
+			tArg = getopt.OptionArg()
+		case 'X':
+			attOpts.xattrs = true
+		case 'x':
+			var err error
+			if attOpts.sidecar, err = sidecarFormatFromArgument(getopt.OptionArg().String()); err != nil {
+				log.Fatal(err)
+			}
+		case 'z':
+			zArg = getopt.OptionArg()
+		}
+	}
+
+	if err := getopt.Err(); err != nil {
+		log.Fatal(err)
+	}
+
+	if msgOpts.period != periodNone && msgOpts.format == formatMaildir {
+		log.Fatal("-P is not supported with the maildir format")
+	}
+
+	msgOpts.redact = msgOpts.pseudonymFile != "" || rflag
+
+	var exportDir string
+	args = getopt.Args()
+	switch len(args) {
+	case 0:
+		exportDir = "."
+	case 1:
+		exportDir = args[0]
+	default:
+		return cmdUsage
+	}
+
+	if attOpts.xattrs && archiveFormatFromPath(exportDir) == archiveZip {
+		log.Fatal("extended attributes are not supported in zip archives")
+	}
+
+	if err := prepareOutputDir(exportDir, iflag, eArg.Set()); err != nil {
+		log.Fatal(err)
+	}
+
+	key, err := encryptionKeyFromArgument(kArg)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	passphrase, err := passphraseFromArgument(eArg)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	signalDir, err := signalDirFromArgument(dArg, Bflag)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	filter, senders, err := parseMessageFilter(FArgs)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	timeFormat, err := timeFormatFromArguments(zArg, tArg)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if msgOpts.format == formatCSV && !timeFormat.iso8601() {
+		log.Fatal("the csv format supports only ISO 8601 time formats")
+	}
+
+	if msgOpts.format == formatTemplate {
+		msgOpts.template, err = parseMessageTemplate(templateFile, timeFormat)
+		if err != nil {
+			log.Fatal(err)
+		}
+		msgOpts.templateExt = templateExtension(templateFile)
+	}
+
+	filter.Interval, err = intervalFromArgument(sArg, timeFormat.loc)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	filter.Time, err = messageTimeFromArgument(TArg)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	attOpts.attFilter, err = parseAttachmentFilter(AArgs)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	sanitiser, err := filenameSanitiserFromArgument(SArg)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	msgOpts.exportDir = exportDir
+	msgOpts.selectors = selectors
+	msgOpts.filter = filter
+	msgOpts.senders = senders
+	msgOpts.sanitiser = sanitiser
+	msgOpts.timeFormat = timeFormat
+	msgOpts.incremental = iflag
+
+	attOpts.exportDir = exportDir
+	attOpts.selectors = selectors
+	attOpts.sanitiser = sanitiser
+	attOpts.timeFormat = timeFormat
+	attOpts.incremental = iflag
+
+	avtOpts.exportDir = exportDir
+	avtOpts.selectors = selectors
+	avtOpts.sanitiser = sanitiser
+	avtOpts.incremental = iflag
+
+	if err := unveilSignalDir(signalDir); err != nil {
+		log.Fatal(err)
+	}
+
+	if err := unveilOutputDir(exportDir); err != nil {
+		log.Fatal(err)
+	}
+
+	if err := unveilPseudonymFile(msgOpts.pseudonymFile); err != nil {
+		log.Fatal(err)
+	}
+
+	// For SQLite/SQLCipher
+	if err := openbsd.Unveil("/dev/urandom", "r"); err != nil {
+		log.Fatal(err)
+	}
+
+	if err := unveilMimeFiles(); err != nil {
+		log.Fatal(err)
+	}
+
+	if attOpts.mtime == mtimeNone {
+		if err := openbsd.Pledge("stdio rpath wpath cpath flock"); err != nil {
+			log.Fatal(err)
+		}
+	} else {
+		if err := openbsd.Pledge("stdio rpath wpath cpath flock fattr"); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	if err := addContentTypes(); err != nil {
+		log.Print(err)
+	}
+
+	ctx, err := signal.Open(Bflag, signalDir, key)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer ctx.Close()
+
+	ctx.SetMaskBodies(rflag)
+
+	if !exportAll(ctx, exportDir, passphrase, &msgOpts, &attOpts, &avtOpts) {
+		return cmdError
+	}
+
+	return cmdOK
+}
+
+// exportAll exports the messages, attachments and avatars of the selected
+// conversations. The messages of each conversation are retrieved only once.
+func exportAll(ctx *signal.Context, exportDir string, passphrase []byte, msgOpts *messageExportOptions, attOpts *attachmentExportOptions, avtOpts *avatarExportOptions) bool {
+	d, err := openOutputDir(exportDir, passphrase)
+	if err != nil {
+		log.Print(err)
+		return false
+	}
+	defer func() {
+		if err := d.Close(); err != nil {
+			log.Print(err)
+		}
+	}()
+
+	msgDir, err := d.OpenDir(exportMessagesDir)
+	if err != nil {
+		log.Print(err)
+		return false
+	}
+	defer msgDir.Close()
+
+	attDir, err := d.OpenDir(exportAttachmentsDir)
+	if err != nil {
+		log.Print(err)
+		return false
+	}
+	defer attDir.Close()
+
+	avtDir, err := d.OpenDir(exportAvatarsDir)
+	if err != nil {
+		log.Print(err)
+		return false
+	}
+	defer avtDir.Close()
+
+	var exported map[string]bool
+	if attOpts.incremental {
+		if exported, err = readIncrementalFile(attDir); err != nil {
+			log.Print(err)
+			return false
+		}
+	}
+
+	index, err := readAttachmentIndex(attDir)
+	if err != nil {
+		log.Print(err)
+		return false
+	}
+
+	convs, err := selectConversations(ctx, msgOpts.selectors)
+	if err != nil {
+		log.Print(err)
+		return false
+	}
+
+	if err := resolveMessageFilter(ctx, &msgOpts.filter, msgOpts.senders); err != nil {
+		log.Print(err)
+		return false
+	}
+
+	if msgOpts.pseudonymFile != "" {
+		if err := pseudonymiseRecipients(ctx, msgOpts.pseudonymFile, false); err != nil {
+			log.Print(err)
+			return false
+		}
+	}
+
+	msgOpts.attLinks = make(map[string]string)
+	// The message files are in a subdirectory of the export directory
+	linkDepth := 1 + messageFileDepth(msgOpts)
+
+	ret := true
+	for _, conv := range convs {
+		if !exportRecipientAvatars(ctx, avtDir, conv.Recipient, avtOpts) {
+			ret = false
+		}
+
+		msgs, err := ctx.ConversationMessages(&conv, msgOpts.filter)
+		if err != nil {
+			log.Print(err)
+			ret = false
+			continue
+		}
+
+		var ok bool
+		if ok, exported = exportAttachmentList(ctx, attDir, &conv, msgs, exported, index, attOpts); !ok {
+			ret = false
+		}
+
+		for _, msg := range msgs {
+			for _, att := range msg.Attachments {
+				id := attachmentID(&att)
+				if p, ok := index[id]; ok {
+					msgOpts.attLinks[id] = relativeLink(linkDepth, path.Join(exportAttachmentsDir, p))
+				}
+			}
+		}
+
+		if err := exportMessageList(ctx, msgDir, &conv, msgs, msgOpts); err != nil {
+			log.Print(err)
+			ret = false
+		}
+	}
+
+	if err := writeAttachmentIndex(attDir, exportDir, index); err != nil {
+		log.Print(err)
+		ret = false
+	}
+
+	if attOpts.incremental {
+		if err := writeIncrementalFile(attDir, exported); err != nil {
+			log.Print(err)
+			ret = false
+		}
+	}
+
+	return ret
+}
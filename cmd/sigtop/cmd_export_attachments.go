@@ -16,19 +16,21 @@ package main
 
 import (
 	"bufio"
+	"bytes"
 	"errors"
 	"fmt"
 	"io/fs"
 	"log"
 	"os"
+	"path"
 	"path/filepath"
 	"strings"
 	"time"
 
 	"github.com/tbvdm/go-openbsd"
-	"github.com/tbvdm/sigtop/at"
 	"github.com/tbvdm/sigtop/filename"
 	"github.com/tbvdm/sigtop/getopt"
+	"github.com/tbvdm/sigtop/imgmeta"
 	"github.com/tbvdm/sigtop/signal"
 )
 
@@ -45,18 +47,27 @@ const (
 )
 
 type attachmentExportOptions struct {
-	exportDir   string
-	selectors   []string
-	interval    signal.Interval
-	sanitiser   *filename.Sanitiser
-	mtime       mtimeMode
-	incremental bool
+	exportDir     string
+	passphrase    []byte
+	selectors     []string
+	filter        signal.MessageFilter
+	senders       []string
+	attFilter     attachmentFilter
+	sanitiser     *filename.Sanitiser
+	timeFormat    *timeFormat
+	mtime         mtimeMode
+	nameTemplate  *filenameTemplate
+	sidecar       sidecarFormat
+	xattrs        bool
+	strip         bool
+	pseudonymFile string
+	incremental   bool
 }
 
 var cmdExportAttachmentsEntry = cmdEntry{
 	name:  "export-attachments",
 	alias: "att",
-	usage: "[-BiMm] [-c conversation] [-d signal-directory] [-k [system:]keyfile] [-S sanitiser] [-s interval] [directory]",
+	usage: "[-BEiMmrX] [-A filter] [-c conversation] [-d signal-directory] [-e passfile] [-F filter] [-k [system:]keyfile] [-N name-template] [-R pseudonym-file] [-S sanitiser] [-s interval] [-T time] [-t time-format] [-x sidecar-format] [-z time-zone] [directory]",
 	exec:  cmdExportAttachments,
 }
 
@@ -66,32 +77,64 @@ func cmdExportAttachments(args []string) cmdStatus {
 		incremental: false,
 	}
 
-	getopt.ParseArgs("Bc:d:ik:Mmp:S:s:", args)
-	var dArg, kArg, SArg, sArg getopt.Arg
+	getopt.ParseArgs("A:Bc:d:Ee:F:ik:MmN:p:R:rS:s:T:t:Xx:z:", args)
+	var dArg, eArg, kArg, SArg, sArg, TArg, tArg, zArg getopt.Arg
+	var AArgs, FArgs []string
 	Bflag := false
+	rflag := false
 	for getopt.Next() {
 		switch getopt.Option() {
+		case 'A':
+			AArgs = append(AArgs, getopt.OptionArg().String())
 		case 'B':
 			Bflag = true
 		case 'c':
 			opts.selectors = append(opts.selectors, getopt.OptionArg().String())
 		case 'd':
 			dArg = getopt.OptionArg()
+		case 'E':
+			opts.strip = true
+		case 'e':
+			eArg = getopt.OptionArg()
+		case 'F':
+			FArgs = append(FArgs, getopt.OptionArg().String())
 		case 'i':
 			opts.incremental = true
 		case 'M':
 			opts.mtime = mtimeSent
 		case 'm':
 			opts.mtime = mtimeRecv
+		case 'N':
+			var err error
+			if opts.nameTemplate, err = parseFilenameTemplate(getopt.OptionArg().String()); err != nil {
+				log.Fatal(err)
+			}
 		case 'p':
 			log.Print("-p is deprecated; use -k instead")
 			fallthrough
 		case 'k':
 			kArg = getopt.OptionArg()
+		case 'R':
+			opts.pseudonymFile = getopt.OptionArg().String()
+		case 'r':
+			rflag = true
 		case 'S':
 			SArg = getopt.OptionArg()
 		case 's':
 			sArg = getopt.OptionArg()
+		case 'T':
+			TArg = getopt.OptionArg()
+		case 't':
+			tArg = getopt.OptionArg()
+		case 'X':
+			opts.xattrs = true
+		case 'x':
+			var err error
+			if opts.sidecar, err = sidecarFormatFromArgument(getopt.OptionArg().String()); err != nil {
+				log.Fatal(err)
+			}
+		case 'z':
+			zArg = getopt.OptionArg()
 		}
 	}
 
@@ -105,24 +148,54 @@ func cmdExportAttachments(args []string) cmdStatus {
 		opts.exportDir = "."
 	case 1:
 		opts.exportDir = args[0]
-		if err := os.Mkdir(opts.exportDir, 0777); err != nil && !errors.Is(err, fs.ErrExist) {
-			log.Fatal(err)
-		}
 	default:
 		return cmdUsage
 	}
 
+	if opts.xattrs && archiveFormatFromPath(opts.exportDir) == archiveZip {
+		log.Fatal("extended attributes are not supported in zip archives")
+	}
+
+	if err := prepareOutputDir(opts.exportDir, opts.incremental, eArg.Set()); err != nil {
+		log.Fatal(err)
+	}
+
 	key, err := encryptionKeyFromArgument(kArg)
 	if err != nil {
 		log.Fatal(err)
 	}
 
+	opts.passphrase, err = passphraseFromArgument(eArg)
+	if err != nil {
+		log.Fatal(err)
+	}
+
 	signalDir, err := signalDirFromArgument(dArg, Bflag)
 	if err != nil {
 		log.Fatal(err)
 	}
 
-	opts.interval, err = intervalFromArgument(sArg)
+	opts.filter, opts.senders, err = parseMessageFilter(FArgs)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	opts.timeFormat, err = timeFormatFromArguments(zArg, tArg)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	opts.filter.Interval, err = intervalFromArgument(sArg, opts.timeFormat.loc)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	opts.filter.Time, err = messageTimeFromArgument(TArg)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	opts.attFilter, err = parseAttachmentFilter(AArgs)
 	if err != nil {
 		log.Fatal(err)
 	}
@@ -136,7 +209,11 @@ func cmdExportAttachments(args []string) cmdStatus {
 		log.Fatal(err)
 	}
 
-	if err := openbsd.Unveil(opts.exportDir, "rwc"); err != nil {
+	if err := unveilOutputDir(opts.exportDir); err != nil {
+		log.Fatal(err)
+	}
+
+	if err := unveilPseudonymFile(opts.pseudonymFile); err != nil {
 		log.Fatal(err)
 	}
 
@@ -169,6 +246,8 @@ func cmdExportAttachments(args []string) cmdStatus {
 	}
 	defer ctx.Close()
 
+	ctx.SetMaskBodies(rflag)
+
 	if !exportAttachments(ctx, &opts) {
 		return cmdError
 	}
@@ -177,12 +256,16 @@ func cmdExportAttachments(args []string) cmdStatus {
 }
 
 func exportAttachments(ctx *signal.Context, opts *attachmentExportOptions) bool {
-	d, err := at.Open(opts.exportDir)
+	d, err := openOutputDir(opts.exportDir, opts.passphrase)
 	if err != nil {
 		log.Print(err)
 		return false
 	}
-	defer d.Close()
+	defer func() {
+		if err := d.Close(); err != nil {
+			log.Print(err)
+		}
+	}()
 
 	var exported map[string]bool
 	if opts.incremental {
@@ -193,20 +276,43 @@ func exportAttachments(ctx *signal.Context, opts *attachmentExportOptions) bool
 		}
 	}
 
+	index, err := readAttachmentIndex(d)
+	if err != nil {
+		log.Print(err)
+		return false
+	}
+
 	convs, err := selectConversations(ctx, opts.selectors)
 	if err != nil {
 		log.Print(err)
 		return false
 	}
 
+	if err := resolveMessageFilter(ctx, &opts.filter, opts.senders); err != nil {
+		log.Print(err)
+		return false
+	}
+
+	if opts.pseudonymFile != "" {
+		if err := pseudonymiseRecipients(ctx, opts.pseudonymFile, false); err != nil {
+			log.Print(err)
+			return false
+		}
+	}
+
 	ret := true
 	for _, conv := range convs {
 		var ok bool
-		if ok, exported = exportConversationAttachments(ctx, d, &conv, exported, opts); !ok {
+		if ok, exported = exportConversationAttachments(ctx, d, &conv, exported, index, opts); !ok {
 			ret = false
 		}
 	}
 
+	if err := writeAttachmentIndex(d, opts.exportDir, index); err != nil {
+		log.Print(err)
+		ret = false
+	}
+
 	if opts.incremental {
 		if err := writeIncrementalFile(d, exported); err != nil {
 			log.Print(err)
@@ -217,97 +323,164 @@ func exportAttachments(ctx *signal.Context, opts *attachmentExportOptions) bool
 	return ret
 }
 
-func exportConversationAttachments(ctx *signal.Context, d at.Dir, conv *signal.Conversation, exported map[string]bool, opts *attachmentExportOptions) (bool, map[string]bool) {
-	atts, err := ctx.ConversationAttachments(conv, opts.interval)
+func exportConversationAttachments(ctx *signal.Context, d outputDir, conv *signal.Conversation, exported map[string]bool, index map[string]string, opts *attachmentExportOptions) (bool, map[string]bool) {
+	msgs, err := ctx.ConversationMessages(conv, opts.filter)
 	if err != nil {
 		log.Print(err)
 		return false, exported
 	}
 
-	if len(atts) == 0 {
-		return true, exported
-	}
+	return exportAttachmentList(ctx, d, conv, msgs, exported, index, opts)
+}
 
-	cd, err := conversationDir(d, conv, opts)
-	if err != nil {
-		log.Print(err)
-		return false, exported
-	}
+// exportAttachmentList exports the attachments of a list of messages. The
+// messages are needed to write sidecar files.
+func exportAttachmentList(ctx *signal.Context, d outputDir, conv *signal.Conversation, msgs []signal.Message, exported map[string]bool, index map[string]string, opts *attachmentExportOptions) (bool, map[string]bool) {
+	dirs := make(map[string]outputDir)
+	defer func() {
+		for _, sd := range dirs {
+			sd.Close()
+		}
+	}()
 
 	ret := true
-	for _, att := range atts {
-		id := filepath.Base(att.Path)
-		if opts.incremental && exported[id] {
-			continue
-		}
-		if att.Path == "" {
-			var msg string
-			if att.Pending {
-				msg = "skipping pending attachment"
-			} else {
-				msg = "skipping attachment without path"
+	for i := range msgs {
+		msg := &msgs[i]
+		for j := range msg.Attachments {
+			if !exportMessageAttachment(ctx, d, dirs, conv, msg, j, exported, index, opts) {
 				ret = false
 			}
-			log.Printf("%s (conversation: %q, sent: %s)", msg, conv.Recipient.DisplayName(), time.UnixMilli(att.TimeSent).Format("2006-01-02 15:04:05"))
-			continue
-		}
-		path, err := attachmentFilename(cd, &att, opts)
-		if err != nil {
-			log.Print(err)
-			ret = false
-			continue
 		}
-		if err := copyAttachment(ctx, cd, path, &att); err != nil {
-			log.Print(err)
-			ret = false
-			continue
+	}
+
+	return ret, exported
+}
+
+// exportMessageAttachment exports the attachment at position i of a message.
+func exportMessageAttachment(ctx *signal.Context, d outputDir, dirs map[string]outputDir, conv *signal.Conversation, msg *signal.Message, i int, exported map[string]bool, index map[string]string, opts *attachmentExportOptions) bool {
+	att := &msg.Attachments[i]
+	id := attachmentID(att)
+	if opts.incremental && exported[id] {
+		return true
+	}
+	if !opts.attFilter.match(att) {
+		return true
+	}
+	if att.Path == "" {
+		if att.Pending {
+			log.Printf("skipping pending attachment (conversation: %q, sent: %s)", conv.Recipient.DisplayName(), time.UnixMilli(att.TimeSent).Format("2006-01-02 15:04:05"))
+			return true
 		}
-		if err := setAttachmentModTime(cd, path, &att, opts.mtime); err != nil {
+		log.Printf("skipping attachment without path (conversation: %q, sent: %s)", conv.Recipient.DisplayName(), time.UnixMilli(att.TimeSent).Format("2006-01-02 15:04:05"))
+		return false
+	}
+	p, err := attachmentPath(conv.Recipient, msg, att, i+1, opts)
+	if err != nil {
+		log.Print(err)
+		return false
+	}
+	dir, name := path.Split(p)
+	dir = strings.TrimSuffix(dir, "/")
+	sd, err := openDirPath(d, dir, dirs)
+	if err != nil {
+		log.Print(err)
+		return false
+	}
+	if name, err = uniqueFilename(sd, name); err != nil {
+		log.Print(err)
+		return false
+	}
+	mtime := attachmentModTime(att, opts.mtime)
+	var xattrs []xattr
+	if opts.xattrs {
+		xattrs = attachmentXattrs(conv.Recipient, msg, att, opts.timeFormat)
+	}
+	if err := copyAttachment(ctx, sd, name, att, mtime, xattrs, opts.strip); err != nil {
+		log.Print(err)
+		return false
+	}
+	index[id] = path.Join(dir, name)
+	if opts.incremental {
+		exported[id] = true
+	}
+	if opts.sidecar != sidecarNone {
+		if err := writeSidecar(sd, name, opts.sidecar, conv.Recipient, msg, att, opts.timeFormat, mtime); err != nil {
 			log.Print(err)
-			ret = false
-		}
-		if opts.incremental {
-			exported[id] = true
+			return false
 		}
 	}
-
-	return ret, exported
+	return true
 }
 
-func conversationDir(d at.Dir, conv *signal.Conversation, opts *attachmentExportOptions) (at.Dir, error) {
-	name := recipientFilename(conv.Recipient, "", opts.sanitiser)
-	if err := d.Mkdir(name, 0777); err != nil && !errors.Is(err, fs.ErrExist) {
-		return at.InvalidDir, err
+// openDirPath opens the directory at the slash-separated path dir, creating
+// it and its parents if necessary. Opened directories are cached in dirs.
+func openDirPath(d outputDir, dir string, dirs map[string]outputDir) (outputDir, error) {
+	if dir == "" || dir == "." {
+		return d, nil
 	}
-	return d.OpenDir(name)
+	if sd, ok := dirs[dir]; ok {
+		return sd, nil
+	}
+
+	parent, err := openDirPath(d, path.Dir(dir), dirs)
+	if err != nil {
+		return nil, err
+	}
+	sd, err := parent.OpenDir(path.Base(dir))
+	if err != nil {
+		return nil, err
+	}
+	dirs[dir] = sd
+	return sd, nil
 }
 
-func attachmentFilename(d at.Dir, att *signal.Attachment, opts *attachmentExportOptions) (string, error) {
+// attachmentPath returns the slash-separated path, relative to the export
+// directory, that an attachment is to be exported to. The index is the
+// position of the attachment in its message, starting at 1. The path may
+// still have to be made unique.
+func attachmentPath(conv *signal.Recipient, msg *signal.Message, att *signal.Attachment, index int, opts *attachmentExportOptions) (string, error) {
+	if opts.nameTemplate != nil {
+		return opts.nameTemplate.expand(conv, msg, att, index, opts.timeFormat, opts.sanitiser)
+	}
+
 	var name string
 	if att.FileName != "" {
 		name = opts.sanitiser.Sanitise(att.FileName)
 	} else {
-		var ext string
-		if att.ContentType == "" {
-			log.Printf("attachment without content type (sent: %d)", att.TimeSent)
-		} else {
-			var err error
-			ext, err = extensionFromContentType(att.ContentType)
-			if err != nil {
-				return "", err
-			}
-			if ext == "" {
-				log.Printf("no filename extension for content type %q (sent: %d)", att.ContentType, att.TimeSent)
-			}
+		ext, err := attachmentExtension(att)
+		if err != nil {
+			return "", err
 		}
-		name = "attachment-" + time.UnixMilli(att.TimeSent).Format("2006-01-02-15-04-05") + ext
+		name = opts.sanitiser.Sanitise("attachment-" + opts.timeFormat.format(att.TimeSent, filenameTimeLayout) + ext)
 	}
 
-	return uniqueFilename(d, name)
+	return recipientFilename(conv, "", opts.sanitiser) + "/" + name, nil
 }
 
-func uniqueFilename(d at.Dir, path string) (string, error) {
-	if ok, err := fileExists(d, path); !ok {
+// attachmentExtension returns the filename extension of an attachment. If the
+// attachment has no filename, the extension is derived from the content type.
+func attachmentExtension(att *signal.Attachment) (string, error) {
+	if att.FileName != "" {
+		return filepath.Ext(att.FileName), nil
+	}
+
+	if att.ContentType == "" {
+		log.Printf("attachment without content type (sent: %d)", att.TimeSent)
+		return "", nil
+	}
+
+	ext, err := extensionFromContentType(att.ContentType)
+	if err != nil {
+		return "", err
+	}
+	if ext == "" {
+		log.Printf("no filename extension for content type %q (sent: %d)", att.ContentType, att.TimeSent)
+	}
+	return ext, nil
+}
+
+func uniqueFilename(d outputDir, path string) (string, error) {
+	if ok, err := d.Exists(path); !ok {
 		return path, err
 	}
 
@@ -316,7 +489,7 @@ func uniqueFilename(d at.Dir, path string) (string, error) {
 
 	for i := 2; i > 0; i++ {
 		newPath := fmt.Sprintf("%s-%d%s", prefix, i, suffix)
-		if ok, err := fileExists(d, newPath); !ok {
+		if ok, err := d.Exists(newPath); !ok {
 			return newPath, err
 		}
 	}
@@ -324,47 +497,124 @@ func uniqueFilename(d at.Dir, path string) (string, error) {
 	return "", fmt.Errorf("%s: cannot generate unique name", path)
 }
 
-func fileExists(d at.Dir, path string) (bool, error) {
-	if _, err := d.Stat(path, at.SymlinkNoFollow); err != nil {
-		if !errors.Is(err, fs.ErrNotExist) {
-			return false, err
+// copyAttachment exports an attachment. If strip is true, metadata is removed
+// from images.
+func copyAttachment(ctx *signal.Context, d outputDir, path string, att *signal.Attachment, mtime time.Time, xattrs []xattr, strip bool) error {
+	f, err := d.Create(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, mtime)
+	if err != nil {
+		return err
+	}
+	// Extended attributes are set first, because they are part of the tar
+	// header. Keep the attachment if they cannot be set.
+	for _, xa := range xattrs {
+		if err := f.SetXattr(xa.name, []byte(xa.value)); err != nil {
+			log.Printf("cannot set extended attributes of %s: %v", path, err)
+			break
 		}
-		return false, nil
 	}
-	return true, nil
+	if strip {
+		err = writeStrippedAttachment(ctx, att, f)
+	} else {
+		err = writeAttachment(ctx, att, f)
+	}
+	if err != nil {
+		f.Discard()
+		return fmt.Errorf("cannot export %s: %w", path, err)
+	}
+
+	return f.Close()
 }
 
-func copyAttachment(ctx *signal.Context, d at.Dir, path string, att *signal.Attachment) error {
-	f, err := d.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0666)
-	if err != nil {
+// writeStrippedAttachment writes an attachment with the metadata removed from
+// JPEG, PNG and WebP images. Other attachments are written unchanged. Only
+// images of a supported type are read into memory.
+func writeStrippedAttachment(ctx *signal.Context, att *signal.Attachment, f outputFile) error {
+	contentType, _, _ := strings.Cut(strings.ToLower(att.ContentType), ";")
+	contentType = strings.TrimSpace(contentType)
+	switch contentType {
+	case "image/jpeg", "image/png", "image/webp":
+	default:
+		if strings.HasPrefix(contentType, "image/") {
+			log.Printf("cannot remove metadata from attachment of type %s (sent: %d)", att.ContentType, att.TimeSent)
+		}
+		return writeAttachment(ctx, att, f)
+	}
+
+	var buf bytes.Buffer
+	if err := ctx.WriteAttachment(att, &buf); err != nil {
 		return err
 	}
-	if err := ctx.WriteAttachment(att, f); err != nil {
-		f.Close()
-		d.Unlink(path, 0)
-		return fmt.Errorf("cannot export %s: %w", path, err)
+
+	data, ok, err := imgmeta.Strip(buf.Bytes())
+	if err != nil {
+		return fmt.Errorf("cannot remove metadata: %w", err)
+	}
+	if !ok {
+		log.Printf("cannot remove metadata from attachment of type %s (sent: %d): unrecognised image data", att.ContentType, att.TimeSent)
 	}
 
-	return f.Close()
+	f.SetSize(int64(len(data)))
+	_, err = f.Write(data)
+	return err
+}
+
+// writeAttachment writes an attachment. Its size is set first, so that it is
+// not held in memory when it is written to a tar archive.
+func writeAttachment(ctx *signal.Context, att *signal.Attachment, f outputFile) error {
+	size, err := ctx.AttachmentSize(att)
+	if err != nil {
+		return err
+	}
+	f.SetSize(size)
+	return ctx.WriteAttachment(att, f)
+}
+
+// Prefix of the names of the extended attributes of exported attachments. The
+// names follow the freedesktop.org conventions for user.xdg.origin.*
+// attributes.
+const xattrPrefix = "user.xdg.origin.signal."
+
+type xattr struct {
+	name  string
+	value string
+}
+
+// attachmentXattrs returns the extended attributes to set on an exported
+// attachment.
+func attachmentXattrs(conv *signal.Recipient, msg *signal.Message, att *signal.Attachment, tf *timeFormat) []xattr {
+	xattrs := []xattr{
+		{xattrPrefix + "conversation", conv.DisplayName()},
+		{xattrPrefix + "sender", sidecarSender(msg)},
+	}
+	if sent := sidecarTime(tf, msg.TimeSent); sent != "" {
+		xattrs = append(xattrs, xattr{xattrPrefix + "sent", sent})
+	}
+	if recv := sidecarTime(tf, msg.TimeRecv); recv != "" {
+		xattrs = append(xattrs, xattr{xattrPrefix + "received", recv})
+	}
+	if att.FileName != "" {
+		xattrs = append(xattrs, xattr{xattrPrefix + "filename", att.FileName})
+	}
+	return xattrs
 }
 
-func setAttachmentModTime(d at.Dir, path string, att *signal.Attachment, mode mtimeMode) error {
-	var mtime int64
+// attachmentModTime returns the modification time to set on an exported
+// attachment, or the zero time if the modification time is not to be set.
+func attachmentModTime(att *signal.Attachment, mode mtimeMode) time.Time {
 	switch mode {
 	case mtimeSent:
-		mtime = att.TimeSent
+		return time.UnixMilli(att.TimeSent)
 	case mtimeRecv:
-		mtime = att.TimeRecv
+		return time.UnixMilli(att.TimeRecv)
 	default:
-		return nil
+		return time.Time{}
 	}
-	return d.Utimes(path, at.UtimeOmit, time.UnixMilli(mtime), at.SymlinkNoFollow)
 }
 
-func readIncrementalFile(d at.Dir) (map[string]bool, error) {
+func readIncrementalFile(d outputDir) (map[string]bool, error) {
 	exported := make(map[string]bool)
 
-	f, err := d.OpenFile(incrementalFile, os.O_RDONLY, 0666)
+	f, err := d.Open(incrementalFile)
 	if err != nil {
 		if errors.Is(err, fs.ErrNotExist) {
 			return exported, nil
@@ -381,15 +631,15 @@ func readIncrementalFile(d at.Dir) (map[string]bool, error) {
 	return exported, s.Err()
 }
 
-func writeIncrementalFile(d at.Dir, exported map[string]bool) error {
-	f, err := d.OpenFile(incrementalFile, os.O_WRONLY|os.O_CREATE, 0666)
+func writeIncrementalFile(d outputDir, exported map[string]bool) error {
+	f, err := d.Create(incrementalFile, os.O_WRONLY|os.O_CREATE, time.Time{})
 	if err != nil {
 		return err
 	}
 
 	for id := range exported {
 		if _, err := fmt.Fprintln(f, id); err != nil {
-			f.Close()
+			f.Discard()
 			return err
 		}
 	}
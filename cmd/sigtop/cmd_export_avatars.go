@@ -16,36 +16,37 @@ package main
 
 import (
 	"bytes"
-	"errors"
-	"io/fs"
 	"log"
 	"os"
+	"time"
 
 	"github.com/tbvdm/go-openbsd"
-	"github.com/tbvdm/sigtop/at"
 	"github.com/tbvdm/sigtop/filename"
 	"github.com/tbvdm/sigtop/getopt"
 	"github.com/tbvdm/sigtop/signal"
 )
 
 type avatarExportOptions struct {
-	exportDir string
-	selectors []string
-	sanitiser *filename.Sanitiser
+	exportDir     string
+	passphrase    []byte
+	selectors     []string
+	sanitiser     *filename.Sanitiser
+	pseudonymFile string
+	incremental   bool
 }
 
 var cmdExportAvatarsEntry = cmdEntry{
 	name:  "export-avatars",
 	alias: "avt",
-	usage: "[-B] [-c conversation] [-d signal-directory] [-k [system:]keyfile] [-S sanitiser] [directory]",
+	usage: "[-B] [-c conversation] [-d signal-directory] [-e passfile] [-k [system:]keyfile] [-R pseudonym-file] [-S sanitiser] [directory]",
 	exec:  cmdExportAvatars,
 }
 
 func cmdExportAvatars(args []string) cmdStatus {
 	opts := avatarExportOptions{}
 
-	getopt.ParseArgs("Bc:d:k:p:S:", args)
-	var dArg, kArg, SArg getopt.Arg
+	getopt.ParseArgs("Bc:d:e:k:p:R:S:", args)
+	var dArg, eArg, kArg, SArg getopt.Arg
 	Bflag := false
 	for getopt.Next() {
 		switch getopt.Option() {
@@ -55,11 +56,15 @@ func cmdExportAvatars(args []string) cmdStatus {
 			opts.selectors = append(opts.selectors, getopt.OptionArg().String())
 		case 'd':
 			dArg = getopt.OptionArg()
+		case 'e':
+			eArg = getopt.OptionArg()
 		case 'p':
 			log.Print("-p is deprecated; use -k instead")
 			fallthrough
 		case 'k':
 			kArg = getopt.OptionArg()
+		case 'R':
+			opts.pseudonymFile = getopt.OptionArg().String()
 		case 'S':
 			SArg = getopt.OptionArg()
 		}
@@ -75,18 +80,24 @@ func cmdExportAvatars(args []string) cmdStatus {
 		opts.exportDir = "."
 	case 1:
 		opts.exportDir = args[0]
-		if err := os.Mkdir(opts.exportDir, 0777); err != nil && !errors.Is(err, fs.ErrExist) {
-			log.Fatal(err)
-		}
 	default:
 		return cmdUsage
 	}
 
+	if err := prepareOutputDir(opts.exportDir, false, eArg.Set()); err != nil {
+		log.Fatal(err)
+	}
+
 	key, err := encryptionKeyFromArgument(kArg)
 	if err != nil {
 		log.Fatal(err)
 	}
 
+	opts.passphrase, err = passphraseFromArgument(eArg)
+	if err != nil {
+		log.Fatal(err)
+	}
+
 	signalDir, err := signalDirFromArgument(dArg, Bflag)
 	if err != nil {
 		log.Fatal(err)
@@ -101,7 +112,11 @@ func cmdExportAvatars(args []string) cmdStatus {
 		log.Fatal(err)
 	}
 
-	if err := openbsd.Unveil(opts.exportDir, "rwc"); err != nil {
+	if err := unveilOutputDir(opts.exportDir); err != nil {
+		log.Fatal(err)
+	}
+
+	if err := unveilPseudonymFile(opts.pseudonymFile); err != nil {
 		log.Fatal(err)
 	}
 
@@ -128,12 +143,16 @@ func cmdExportAvatars(args []string) cmdStatus {
 }
 
 func exportAvatars(ctx *signal.Context, opts *avatarExportOptions) bool {
-	d, err := at.Open(opts.exportDir)
+	d, err := openOutputDir(opts.exportDir, opts.passphrase)
 	if err != nil {
 		log.Print(err)
 		return false
 	}
-	defer d.Close()
+	defer func() {
+		if err := d.Close(); err != nil {
+			log.Print(err)
+		}
+	}()
 
 	convs, err := selectConversations(ctx, opts.selectors)
 	if err != nil {
@@ -141,6 +160,13 @@ func exportAvatars(ctx *signal.Context, opts *avatarExportOptions) bool {
 		return false
 	}
 
+	if opts.pseudonymFile != "" {
+		if err := pseudonymiseRecipients(ctx, opts.pseudonymFile, true); err != nil {
+			log.Print(err)
+			return false
+		}
+	}
+
 	ret := true
 	for _, conv := range convs {
 		if !exportRecipientAvatars(ctx, d, conv.Recipient, opts) {
@@ -151,7 +177,7 @@ func exportAvatars(ctx *signal.Context, opts *avatarExportOptions) bool {
 	return ret
 }
 
-func exportRecipientAvatars(ctx *signal.Context, d at.Dir, rpt *signal.Recipient, opts *avatarExportOptions) bool {
+func exportRecipientAvatars(ctx *signal.Context, d outputDir, rpt *signal.Recipient, opts *avatarExportOptions) bool {
 	ret := true
 
 	detail := ""
@@ -176,18 +202,23 @@ func exportRecipientAvatars(ctx *signal.Context, d at.Dir, rpt *signal.Recipient
 	return ret
 }
 
-func exportRecipientAvatar(ctx *signal.Context, d at.Dir, rpt *signal.Recipient, avt *signal.Avatar, detail string, opts *avatarExportOptions) error {
+func exportRecipientAvatar(ctx *signal.Context, d outputDir, rpt *signal.Recipient, avt *signal.Avatar, detail string, opts *avatarExportOptions) error {
 	data, err := ctx.ReadAvatar(avt)
 	if err != nil {
 		return err
 	}
 
-	f, err := d.OpenFile(avatarFilename(rpt, detail, data, opts), os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0666)
+	flags := os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+	if !opts.incremental {
+		flags |= os.O_EXCL
+	}
+
+	f, err := d.Create(avatarFilename(rpt, detail, data, opts), flags, time.Time{})
 	if err != nil {
 		return err
 	}
 	if _, err = f.Write(data); err != nil {
-		f.Close()
+		f.Discard()
 		return err
 	}
 
@@ -195,17 +226,22 @@ func exportRecipientAvatar(ctx *signal.Context, d at.Dir, rpt *signal.Recipient,
 }
 
 func avatarFilename(rpt *signal.Recipient, detail string, data []byte, opts *avatarExportOptions) string {
+	ext, _ := avatarType(data)
+	return recipientFilenameWithDetail(rpt, detail, ext, opts.sanitiser)
+}
+
+// avatarType returns the filename extension and content type of an avatar
+func avatarType(data []byte) (string, string) {
 	equals := func(b []byte, s string) bool { return bytes.Equal(b, []byte(s)) }
 
-	var ext string
 	switch {
 	case len(data) >= 3 && equals(data[:3], "\xff\xd8\xff"):
-		ext = ".jpg"
+		return ".jpg", "image/jpeg"
 	case len(data) >= 8 && equals(data[:8], "\x89PNG\r\n\x1a\n"):
-		ext = ".png"
+		return ".png", "image/png"
 	case len(data) >= 12 && equals(data[:4], "RIFF") && equals(data[8:12], "WEBP"):
-		ext = ".webp"
+		return ".webp", "image/webp"
+	default:
+		return "", ""
 	}
-
-	return recipientFilenameWithDetail(rpt, detail, ext, opts.sanitiser)
 }
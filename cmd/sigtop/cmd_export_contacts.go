@@ -0,0 +1,311 @@
+// Copyright (c) 2026 Tim van der Molen <tim@kariliq.nl>
+//
+// Permission to use, copy, modify, and distribute this software for any
+// purpose with or without fee is hereby granted, provided that the above
+// copyright notice and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+// WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+// ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+// WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+// ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+// OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+package main
+
+import (
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/tbvdm/go-openbsd"
+	"github.com/tbvdm/sigtop/cryptio"
+	"github.com/tbvdm/sigtop/errio"
+	"github.com/tbvdm/sigtop/filename"
+	"github.com/tbvdm/sigtop/getopt"
+	"github.com/tbvdm/sigtop/signal"
+)
+
+type contactExportOptions struct {
+	exportDir     string
+	passphrase    []byte
+	outputFile    string
+	selectors     []string
+	sanitiser     *filename.Sanitiser
+	pseudonymFile string
+}
+
+var cmdExportContactsEntry = cmdEntry{
+	name:  "export-contacts",
+	alias: "vcf",
+	usage: "[-B] [-c conversation] [-d signal-directory] [-e passfile] [-k [system:]keyfile] [-o file] [-R pseudonym-file] [-S sanitiser] [directory]",
+	exec:  cmdExportContacts,
+}
+
+func cmdExportContacts(args []string) cmdStatus {
+	opts := contactExportOptions{}
+
+	getopt.ParseArgs("Bc:d:e:k:o:p:R:S:", args)
+	var dArg, eArg, kArg, SArg getopt.Arg
+	Bflag := false
+	for getopt.Next() {
+		switch getopt.Option() {
+		case 'B':
+			Bflag = true
+		case 'c':
+			opts.selectors = append(opts.selectors, getopt.OptionArg().String())
+		case 'd':
+			dArg = getopt.OptionArg()
+		case 'e':
+			eArg = getopt.OptionArg()
+		case 'o':
+			opts.outputFile = getopt.OptionArg().String()
+		case 'p':
+			log.Print("-p is deprecated; use -k instead")
+			fallthrough
+		case 'k':
+			kArg = getopt.OptionArg()
+		case 'R':
+			opts.pseudonymFile = getopt.OptionArg().String()
+		case 'S':
+			SArg = getopt.OptionArg()
+		}
+	}
+
+	if err := getopt.Err(); err != nil {
+		log.Fatal(err)
+	}
+
+	args = getopt.Args()
+	switch {
+	case opts.outputFile != "":
+		if len(args) > 0 {
+			return cmdUsage
+		}
+	case len(args) == 0:
+		opts.exportDir = "."
+	case len(args) == 1:
+		opts.exportDir = args[0]
+	default:
+		return cmdUsage
+	}
+
+	if opts.exportDir != "" {
+		if err := prepareOutputDir(opts.exportDir, false, eArg.Set()); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	key, err := encryptionKeyFromArgument(kArg)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	opts.passphrase, err = passphraseFromArgument(eArg)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	signalDir, err := signalDirFromArgument(dArg, Bflag)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	opts.sanitiser, err = filenameSanitiserFromArgument(SArg)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if err := unveilSignalDir(signalDir); err != nil {
+		log.Fatal(err)
+	}
+
+	if opts.outputFile != "" {
+		if opts.outputFile != "-" {
+			if err := openbsd.Unveil(filepath.Dir(opts.outputFile), "rwc"); err != nil {
+				log.Fatal(err)
+			}
+		}
+	} else {
+		if err := unveilOutputDir(opts.exportDir); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	if err := unveilPseudonymFile(opts.pseudonymFile); err != nil {
+		log.Fatal(err)
+	}
+
+	// For SQLite/SQLCipher
+	if err := openbsd.Unveil("/dev/urandom", "r"); err != nil {
+		log.Fatal(err)
+	}
+
+	if err := openbsd.Pledge("stdio rpath wpath cpath flock"); err != nil {
+		log.Fatal(err)
+	}
+
+	ctx, err := signal.Open(Bflag, signalDir, key)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer ctx.Close()
+
+	if !exportContacts(ctx, &opts) {
+		return cmdError
+	}
+
+	return cmdOK
+}
+
+func exportContacts(ctx *signal.Context, opts *contactExportOptions) bool {
+	convs, err := selectConversations(ctx, opts.selectors)
+	if err != nil {
+		log.Print(err)
+		return false
+	}
+
+	if opts.pseudonymFile != "" {
+		if err := pseudonymiseRecipients(ctx, opts.pseudonymFile, false); err != nil {
+			log.Print(err)
+			return false
+		}
+	}
+
+	var rpts []*signal.Recipient
+	for _, conv := range convs {
+		if conv.Recipient.Type == signal.RecipientTypeContact {
+			rpts = append(rpts, conv.Recipient)
+		}
+	}
+
+	sort.Slice(rpts, func(i, j int) bool {
+		return rpts[i].DetailedDisplayName() < rpts[j].DetailedDisplayName()
+	})
+
+	if opts.outputFile != "" {
+		return exportContactsToFile(ctx, rpts, opts)
+	}
+
+	d, err := openOutputDir(opts.exportDir, opts.passphrase)
+	if err != nil {
+		log.Print(err)
+		return false
+	}
+	defer func() {
+		if err := d.Close(); err != nil {
+			log.Print(err)
+		}
+	}()
+
+	ret := true
+	for _, rpt := range rpts {
+		if !exportContact(ctx, d, rpt, opts) {
+			ret = false
+		}
+	}
+
+	return ret
+}
+
+func exportContact(ctx *signal.Context, d outputDir, rpt *signal.Recipient, opts *contactExportOptions) bool {
+	ret := true
+
+	// Export the contact without photo if the avatar cannot be read
+	photo, err := contactPhoto(ctx, rpt)
+	if err != nil {
+		log.Print(err)
+		ret = false
+	}
+
+	name := recipientFilename(rpt, ".vcf", opts.sanitiser)
+	f, err := d.Create(name, os.O_WRONLY|os.O_CREATE|os.O_EXCL, time.Time{})
+	if err != nil {
+		log.Print(err)
+		return false
+	}
+
+	if err := vcardWriteContact(errio.NewWriter(f), rpt, photo); err != nil {
+		log.Print(err)
+		f.Discard()
+		return false
+	}
+
+	if err := f.Close(); err != nil {
+		log.Print(err)
+		return false
+	}
+
+	return ret
+}
+
+// exportContactsToFile writes the contacts to a single file
+func exportContactsToFile(ctx *signal.Context, rpts []*signal.Recipient, opts *contactExportOptions) bool {
+	d, f, err := openOutputFile(opts.outputFile, false)
+	if err != nil {
+		log.Print(err)
+		return false
+	}
+	if d != nil {
+		defer d.Close()
+	}
+
+	var w io.Writer = f
+	var cw *cryptio.Writer
+	if opts.passphrase != nil {
+		if cw, err = cryptio.NewWriter(f, opts.passphrase); err != nil {
+			log.Print(err)
+			f.Discard()
+			return false
+		}
+		w = cw
+	}
+	ew := errio.NewWriter(w)
+
+	ret := true
+	for _, rpt := range rpts {
+		photo, err := contactPhoto(ctx, rpt)
+		if err != nil {
+			log.Print(err)
+			ret = false
+		}
+		if err := vcardWriteContact(ew, rpt, photo); err != nil {
+			log.Print(err)
+			f.Discard()
+			return false
+		}
+	}
+
+	if cw != nil {
+		if err := cw.Close(); err != nil {
+			log.Print(err)
+			f.Discard()
+			return false
+		}
+	}
+
+	if err := f.Close(); err != nil {
+		log.Print(err)
+		return false
+	}
+
+	return ret
+}
+
+// contactPhoto returns the profile avatar of a contact, or the avatar set by
+// the user if the contact has no profile avatar. If the contact has no avatar,
+// nil is returned.
+func contactPhoto(ctx *signal.Context, rpt *signal.Recipient) ([]byte, error) {
+	avt := &rpt.ProfileAvatar
+	if avt.Path == "" {
+		avt = &rpt.Avatar
+	}
+	if avt.Path == "" {
+		return nil, nil
+	}
+	return ctx.ReadAvatar(avt)
+}
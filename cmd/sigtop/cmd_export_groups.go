@@ -0,0 +1,392 @@
+// Copyright (c) 2026 Tim van der Molen <tim@kariliq.nl>
+//
+// Permission to use, copy, modify, and distribute this software for any
+// purpose with or without fee is hereby granted, provided that the above
+// copyright notice and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+// WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+// ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+// WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+// ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+// OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"log"
+	"path/filepath"
+	"sort"
+	"strconv"
+
+	"github.com/tbvdm/go-openbsd"
+	"github.com/tbvdm/sigtop/cryptio"
+	"github.com/tbvdm/sigtop/errio"
+	"github.com/tbvdm/sigtop/getopt"
+	"github.com/tbvdm/sigtop/signal"
+)
+
+type groupExportOptions struct {
+	passphrase    []byte
+	outputFile    string
+	selectors     []string
+	timeFormat    *timeFormat
+	format        formatMode
+	pseudonymFile string
+}
+
+// A group with its members
+type groupRoster struct {
+	conv    *signal.Conversation
+	created int64
+	members []signal.GroupMember
+}
+
+var groupCSVHeader = []string{
+	"group",
+	"group_id",
+	"group_id_hex",
+	"description",
+	"created",
+	"revision",
+	"member",
+	"member_id",
+	"member_phone",
+	"status",
+	"role",
+	"joined_at_revision",
+	"added_by",
+	"added_by_id",
+	"status_time",
+}
+
+type jsonGroup struct {
+	Name        string            `json:"name"`
+	ID          string            `json:"id"`
+	IDHex       string            `json:"idHex"`
+	Description string            `json:"description,omitempty"`
+	Created     string            `json:"created,omitempty"`
+	Revision    int               `json:"revision"`
+	Members     []jsonGroupMember `json:"members"`
+}
+
+type jsonGroupMember struct {
+	Name             string `json:"name,omitempty"`
+	ID               string `json:"id"`
+	Phone            string `json:"phone,omitempty"`
+	Status           string `json:"status"`
+	Role             string `json:"role,omitempty"`
+	JoinedAtRevision *int   `json:"joinedAtRevision,omitempty"`
+	AddedBy          string `json:"addedBy,omitempty"`
+	AddedByID        string `json:"addedById,omitempty"`
+	StatusTime       string `json:"statusTime,omitempty"`
+}
+
+var cmdExportGroupsEntry = cmdEntry{
+	name:  "export-groups",
+	alias: "grp",
+	usage: "[-B] [-c conversation] [-d signal-directory] [-e passfile] [-f format] [-k [system:]keyfile] [-o file] [-R pseudonym-file] [-t time-format] [-z time-zone]",
+	exec:  cmdExportGroups,
+}
+
+func cmdExportGroups(args []string) cmdStatus {
+	opts := groupExportOptions{
+		outputFile: "-",
+		format:     formatCSV,
+	}
+
+	getopt.ParseArgs("Bc:d:e:f:k:o:p:R:t:z:", args)
+	var dArg, eArg, kArg, tArg, zArg getopt.Arg
+	Bflag := false
+	for getopt.Next() {
+		switch getopt.Option() {
+		case 'B':
+			Bflag = true
+		case 'c':
+			opts.selectors = append(opts.selectors, getopt.OptionArg().String())
+		case 'd':
+			dArg = getopt.OptionArg()
+		case 'e':
+			eArg = getopt.OptionArg()
+		case 'f':
+			switch arg := getopt.OptionArg().String(); arg {
+			case "csv":
+				opts.format = formatCSV
+			case "json":
+				opts.format = formatJSON
+			default:
+				log.Fatalf("invalid format: %s", arg)
+			}
+		case 'o':
+			opts.outputFile = getopt.OptionArg().String()
+		case 'p':
+			log.Print("-p is deprecated; use -k instead")
+			fallthrough
+		case 'k':
+			kArg = getopt.OptionArg()
+		case 'R':
+			opts.pseudonymFile = getopt.OptionArg().String()
+		case 't':
+			tArg = getopt.OptionArg()
+		case 'z':
+			zArg = getopt.OptionArg()
+		}
+	}
+
+	if err := getopt.Err(); err != nil {
+		log.Fatal(err)
+	}
+
+	if len(getopt.Args()) > 0 {
+		return cmdUsage
+	}
+
+	key, err := encryptionKeyFromArgument(kArg)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	opts.passphrase, err = passphraseFromArgument(eArg)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	signalDir, err := signalDirFromArgument(dArg, Bflag)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	opts.timeFormat, err = timeFormatFromArguments(zArg, tArg)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if !opts.timeFormat.iso8601() {
+		log.Fatal("only ISO 8601 time formats are supported")
+	}
+
+	if err := unveilSignalDir(signalDir); err != nil {
+		log.Fatal(err)
+	}
+
+	if opts.outputFile != "-" {
+		if err := openbsd.Unveil(filepath.Dir(opts.outputFile), "rwc"); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	if err := unveilPseudonymFile(opts.pseudonymFile); err != nil {
+		log.Fatal(err)
+	}
+
+	// For SQLite/SQLCipher
+	if err := openbsd.Unveil("/dev/urandom", "r"); err != nil {
+		log.Fatal(err)
+	}
+
+	if err := openbsd.Pledge("stdio rpath wpath cpath flock"); err != nil {
+		log.Fatal(err)
+	}
+
+	ctx, err := signal.Open(Bflag, signalDir, key)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer ctx.Close()
+
+	if !exportGroups(ctx, &opts) {
+		return cmdError
+	}
+
+	return cmdOK
+}
+
+func exportGroups(ctx *signal.Context, opts *groupExportOptions) bool {
+	convs, err := selectConversations(ctx, opts.selectors)
+	if err != nil {
+		log.Print(err)
+		return false
+	}
+
+	var groups []groupRoster
+	for _, conv := range convs {
+		if conv.Recipient.Type != signal.RecipientTypeGroup {
+			continue
+		}
+		grp := groupRoster{conv: &conv}
+		if grp.created, err = ctx.GroupCreationTime(&conv); err != nil {
+			log.Print(err)
+			return false
+		}
+		if grp.members, err = ctx.GroupMembers(&conv.Recipient.Group); err != nil {
+			log.Print(err)
+			return false
+		}
+		groups = append(groups, grp)
+	}
+
+	// The members are looked up first, because pseudonymisation replaces
+	// the details of groups
+	if opts.pseudonymFile != "" {
+		if err := pseudonymiseRecipients(ctx, opts.pseudonymFile, false); err != nil {
+			log.Print(err)
+			return false
+		}
+		for _, grp := range groups {
+			pseudonymiseGroupMembers(grp.members)
+		}
+	}
+
+	sort.Slice(groups, func(i, j int) bool {
+		return groups[i].conv.Recipient.DetailedDisplayName() < groups[j].conv.Recipient.DetailedDisplayName()
+	})
+
+	d, f, err := openOutputFile(opts.outputFile, false)
+	if err != nil {
+		log.Print(err)
+		return false
+	}
+	if d != nil {
+		defer d.Close()
+	}
+
+	var w io.Writer = f
+	var cw *cryptio.Writer
+	if opts.passphrase != nil {
+		if cw, err = cryptio.NewWriter(f, opts.passphrase); err != nil {
+			log.Print(err)
+			f.Discard()
+			return false
+		}
+		w = cw
+	}
+	ew := errio.NewWriter(w)
+
+	switch opts.format {
+	case formatCSV:
+		err = csvWriteGroups(ew, opts.timeFormat, groups)
+	case formatJSON:
+		err = jsonWriteGroups(ew, opts.timeFormat, groups)
+	}
+
+	if err == nil && cw != nil {
+		err = cw.Close()
+	}
+
+	if err != nil {
+		log.Print(err)
+		f.Discard()
+		return false
+	}
+
+	if err := f.Close(); err != nil {
+		log.Print(err)
+		return false
+	}
+
+	return true
+}
+
+// csvWriteGroups writes a row for every group member. A group without known
+// members is written on a row with empty member fields.
+func csvWriteGroups(ew *errio.Writer, tf *timeFormat, groups []groupRoster) error {
+	cw := csvNewWriter(ew)
+	cw.Write(groupCSVHeader)
+	for _, grp := range groups {
+		g := &grp.conv.Recipient.Group
+		groupFields := []string{
+			grp.conv.Recipient.DisplayName(),
+			g.IDBase64URL(),
+			g.IDHex(),
+			g.Description,
+			csvFormatTime(tf, grp.created),
+			strconv.Itoa(g.Revision),
+		}
+		if len(grp.members) == 0 {
+			cw.Write(append(groupFields, make([]string, len(groupCSVHeader)-len(groupFields))...))
+			continue
+		}
+		for _, mbr := range grp.members {
+			m := groupMemberRecord(tf, &mbr)
+			cw.Write(append(groupFields,
+				m.Name,
+				m.ID,
+				m.Phone,
+				m.Status,
+				m.Role,
+				optionalIntString(m.JoinedAtRevision),
+				m.AddedBy,
+				m.AddedByID,
+				m.StatusTime,
+			))
+		}
+	}
+	cw.Flush()
+	return ew.Err()
+}
+
+func jsonWriteGroups(ew *errio.Writer, tf *timeFormat, groups []groupRoster) error {
+	jgrps := make([]jsonGroup, 0, len(groups))
+	for _, grp := range groups {
+		g := &grp.conv.Recipient.Group
+		jgrp := jsonGroup{
+			Name:        grp.conv.Recipient.DisplayName(),
+			ID:          g.IDBase64URL(),
+			IDHex:       g.IDHex(),
+			Description: g.Description,
+			Created:     csvFormatTime(tf, grp.created),
+			Revision:    g.Revision,
+			Members:     make([]jsonGroupMember, 0, len(grp.members)),
+		}
+		for _, mbr := range grp.members {
+			jgrp.Members = append(jgrp.Members, groupMemberRecord(tf, &mbr))
+		}
+		jgrps = append(jgrps, jgrp)
+	}
+
+	enc := json.NewEncoder(ew)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(jgrps); err != nil {
+		return err
+	}
+	return ew.Err()
+}
+
+func groupMemberRecord(tf *timeFormat, mbr *signal.GroupMember) jsonGroupMember {
+	m := jsonGroupMember{
+		ID:         mbr.ServiceID,
+		Status:     mbr.Status.String(),
+		StatusTime: csvFormatTime(tf, mbr.Time),
+	}
+
+	if mbr.Recipient != nil {
+		m.Name = mbr.Recipient.DisplayName()
+		m.Phone = mbr.Recipient.Contact.Phone
+	}
+
+	// Requesting and banned members have no role
+	switch mbr.Status {
+	case signal.GroupMemberStatusMember:
+		m.Role = mbr.Role.String()
+		m.JoinedAtRevision = &mbr.JoinedAtRevision
+	case signal.GroupMemberStatusPending:
+		m.Role = mbr.Role.String()
+	}
+
+	if mbr.AddedBy != nil {
+		m.AddedBy = mbr.AddedBy.DisplayName()
+		m.AddedByID = csvRecipientID(mbr.AddedBy)
+	}
+
+	return m
+}
+
+func optionalIntString(n *int) string {
+	if n == nil {
+		return ""
+	}
+	return strconv.Itoa(*n)
+}
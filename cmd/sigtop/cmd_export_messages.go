@@ -15,13 +15,21 @@
 package main
 
 import (
-	"errors"
-	"io/fs"
+	"fmt"
+	"io"
 	"log"
+	"maps"
 	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"text/template"
+	"time"
 
 	"github.com/tbvdm/go-openbsd"
 	"github.com/tbvdm/sigtop/at"
+	"github.com/tbvdm/sigtop/cryptio"
 	"github.com/tbvdm/sigtop/errio"
 	"github.com/tbvdm/sigtop/filename"
 	"github.com/tbvdm/sigtop/getopt"
@@ -34,21 +42,59 @@ const (
 	formatJSON formatMode = iota
 	formatText
 	formatTextShort
+	formatTemplate
+	formatMarkdown
+	formatCSV
+	formatMbox
+	formatMaildir
+	formatSMS
+	formatTelegram
 )
 
+type periodMode int
+
+const (
+	periodNone periodMode = iota
+	periodDay
+	periodMonth
+	periodYear
+)
+
+func (p periodMode) layout() string {
+	switch p {
+	case periodDay:
+		return "2006-01-02"
+	case periodMonth:
+		return "2006-01"
+	default:
+		return "2006"
+	}
+}
+
 type messageExportOptions struct {
-	exportDir   string
-	selectors   []string
-	interval    signal.Interval
-	sanitiser   *filename.Sanitiser
-	format      formatMode
-	incremental bool
+	exportDir     string
+	passphrase    []byte
+	outputFile    string
+	attDir        string
+	attLinks      map[string]string
+	selectors     []string
+	filter        signal.MessageFilter
+	senders       []string
+	sanitiser     *filename.Sanitiser
+	timeFormat    *timeFormat
+	format        formatMode
+	period        periodMode
+	template      *template.Template
+	templateExt   string
+	pseudonymFile string
+	redact        bool
+	incremental   bool
 }
 
 var cmdExportMessagesEntry = cmdEntry{
 	name:  "export-messages",
 	alias: "msg",
-	usage: "[-Bi] [-c conversation] [-d signal-directory] [-f format] [-k [system:]keyfile] [-S sanitiser] [-s interval] [directory]",
+	usage: "[-Bir] [-a attachment-directory] [-c conversation] [-d signal-directory] [-e passfile] [-F filter] [-f format] [-k [system:]keyfile] [-o file] [-P period] [-R pseudonym-file] [-S sanitiser] [-s interval] [-T time] [-t time-format] [-z time-zone] [directory]",
 	exec:  cmdExportMessages,
 }
 
@@ -58,39 +104,65 @@ func cmdExportMessages(args []string) cmdStatus {
 		incremental: false,
 	}
 
-	getopt.ParseArgs("Bc:d:f:ik:p:S:s:", args)
-	var dArg, kArg, SArg, sArg getopt.Arg
+	getopt.ParseArgs("a:Bc:d:e:F:f:ik:o:P:p:R:rS:s:T:t:z:", args)
+	var dArg, eArg, kArg, SArg, sArg, TArg, tArg, zArg getopt.Arg
+	var FArgs []string
+	var templateFile string
 	Bflag := false
+	rflag := false
 	for getopt.Next() {
 		switch getopt.Option() {
+		case 'a':
+			opts.attDir = getopt.OptionArg().String()
 		case 'B':
 			Bflag = true
 		case 'c':
 			opts.selectors = append(opts.selectors, getopt.OptionArg().String())
 		case 'd':
 			dArg = getopt.OptionArg()
+		case 'e':
+			eArg = getopt.OptionArg()
+		case 'F':
+			FArgs = append(FArgs, getopt.OptionArg().String())
 		case 'f':
-			switch arg := getopt.OptionArg().String(); arg {
-			case "json":
-				opts.format = formatJSON
-			case "text":
-				opts.format = formatText
-			case "text-short":
-				opts.format = formatTextShort
-			default:
-				log.Fatalf("invalid format: %s", arg)
+			var err error
+			if opts.format, templateFile, err = formatFromArgument(getopt.OptionArg().String()); err != nil {
+				log.Fatal(err)
 			}
 		case 'i':
 			opts.incremental = true
+		case 'o':
+			opts.outputFile = getopt.OptionArg().String()
+		case 'P':
+			switch arg := getopt.OptionArg().String(); arg {
+			case "day":
+				opts.period = periodDay
+			case "month":
+				opts.period = periodMonth
+			case "year":
+				opts.period = periodYear
+			default:
+				log.Fatalf("invalid period: %s", arg)
+			}
 		case 'p':
 			log.Print("-p is deprecated; use -k instead")
 			fallthrough
 		case 'k':
 			kArg = getopt.OptionArg()
+		case 'R':
+			opts.pseudonymFile = getopt.OptionArg().String()
+		case 'r':
+			rflag = true
 		case 'S':
 			SArg = getopt.OptionArg()
 		case 's':
 			sArg = getopt.OptionArg()
+		case 'T':
+			TArg = getopt.OptionArg()
+		case 't':
+			tArg = getopt.OptionArg()
+		case 'z':
+			zArg = getopt.OptionArg()
 		}
 	}
 
@@ -98,30 +170,89 @@ func cmdExportMessages(args []string) cmdStatus {
 		log.Fatal(err)
 	}
 
+	if opts.period != periodNone {
+		if opts.outputFile != "" {
+			log.Fatal("-o and -P cannot be used together")
+		}
+		if opts.format == formatMaildir {
+			log.Fatal("-P is not supported with the maildir format")
+		}
+	}
+
+	opts.redact = opts.pseudonymFile != "" || rflag
+
 	args = getopt.Args()
-	switch len(args) {
-	case 0:
+	switch {
+	case opts.outputFile != "":
+		if len(args) > 0 {
+			return cmdUsage
+		}
+		switch opts.format {
+		case formatCSV, formatJSON, formatText, formatTextShort:
+		default:
+			log.Fatal("-o is supported only with the csv, json, text and text-short formats")
+		}
+	case len(args) == 0:
 		opts.exportDir = "."
-	case 1:
+	case len(args) == 1:
 		opts.exportDir = args[0]
-		if err := os.Mkdir(opts.exportDir, 0777); err != nil && !errors.Is(err, fs.ErrExist) {
-			log.Fatal(err)
-		}
 	default:
 		return cmdUsage
 	}
 
+	if opts.attDir != "" && opts.exportDir != "" && archiveFormatFromPath(opts.exportDir) != archiveNone {
+		log.Fatal("-a is not supported when exporting to an archive")
+	}
+
+	if opts.exportDir != "" {
+		if err := prepareOutputDir(opts.exportDir, opts.incremental, eArg.Set()); err != nil {
+			log.Fatal(err)
+		}
+	}
+
 	key, err := encryptionKeyFromArgument(kArg)
 	if err != nil {
 		log.Fatal(err)
 	}
 
+	opts.passphrase, err = passphraseFromArgument(eArg)
+	if err != nil {
+		log.Fatal(err)
+	}
+
 	signalDir, err := signalDirFromArgument(dArg, Bflag)
 	if err != nil {
 		log.Fatal(err)
 	}
 
-	opts.interval, err = intervalFromArgument(sArg)
+	opts.filter, opts.senders, err = parseMessageFilter(FArgs)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	opts.timeFormat, err = timeFormatFromArguments(zArg, tArg)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if opts.format == formatCSV && !opts.timeFormat.iso8601() {
+		log.Fatal("the csv format supports only ISO 8601 time formats")
+	}
+
+	if opts.format == formatTemplate {
+		opts.template, err = parseMessageTemplate(templateFile, opts.timeFormat)
+		if err != nil {
+			log.Fatal(err)
+		}
+		opts.templateExt = templateExtension(templateFile)
+	}
+
+	opts.filter.Interval, err = intervalFromArgument(sArg, opts.timeFormat.loc)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	opts.filter.Time, err = messageTimeFromArgument(TArg)
 	if err != nil {
 		log.Fatal(err)
 	}
@@ -131,11 +262,37 @@ func cmdExportMessages(args []string) cmdStatus {
 		log.Fatal(err)
 	}
 
+	if opts.attDir != "" {
+		// Links are relative to the directory of the message files
+		linkDir := opts.exportDir
+		if opts.outputFile != "" {
+			linkDir = filepath.Dir(opts.outputFile)
+		}
+		opts.attLinks, err = attachmentLinks(linkDir, opts.attDir, messageFileDepth(&opts))
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+
 	if err := unveilSignalDir(signalDir); err != nil {
 		log.Fatal(err)
 	}
 
-	if err := openbsd.Unveil(opts.exportDir, "rwc"); err != nil {
+	if opts.outputFile != "" {
+		if opts.outputFile != "-" {
+			// The file is written to a temporary file in the same
+			// directory first
+			if err := openbsd.Unveil(filepath.Dir(opts.outputFile), "rwc"); err != nil {
+				log.Fatal(err)
+			}
+		}
+	} else {
+		if err := unveilOutputDir(opts.exportDir); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	if err := unveilPseudonymFile(opts.pseudonymFile); err != nil {
 		log.Fatal(err)
 	}
 
@@ -154,6 +311,8 @@ func cmdExportMessages(args []string) cmdStatus {
 	}
 	defer ctx.Close()
 
+	ctx.SetMaskBodies(rflag)
+
 	if !exportMessages(ctx, &opts) {
 		return cmdError
 	}
@@ -161,19 +320,70 @@ func cmdExportMessages(args []string) cmdStatus {
 	return cmdOK
 }
 
+// formatFromArgument parses a message format. For the template format, the
+// template file is returned as well.
+func formatFromArgument(arg string) (formatMode, string, error) {
+	switch arg {
+	case "csv":
+		return formatCSV, "", nil
+	case "json":
+		return formatJSON, "", nil
+	case "maildir":
+		return formatMaildir, "", nil
+	case "markdown":
+		return formatMarkdown, "", nil
+	case "mbox":
+		return formatMbox, "", nil
+	case "sms-backup":
+		return formatSMS, "", nil
+	case "telegram":
+		return formatTelegram, "", nil
+	case "text":
+		return formatText, "", nil
+	case "text-short":
+		return formatTextShort, "", nil
+	}
+
+	file, found := strings.CutPrefix(arg, "template:")
+	if !found || file == "" {
+		return 0, "", fmt.Errorf("invalid format: %s", arg)
+	}
+	return formatTemplate, file, nil
+}
+
 func exportMessages(ctx *signal.Context, opts *messageExportOptions) bool {
-	d, err := at.Open(opts.exportDir)
+	convs, err := selectConversations(ctx, opts.selectors)
 	if err != nil {
 		log.Print(err)
 		return false
 	}
-	defer d.Close()
 
-	convs, err := selectConversations(ctx, opts.selectors)
+	if err := resolveMessageFilter(ctx, &opts.filter, opts.senders); err != nil {
+		log.Print(err)
+		return false
+	}
+
+	if opts.pseudonymFile != "" {
+		if err := pseudonymiseRecipients(ctx, opts.pseudonymFile, false); err != nil {
+			log.Print(err)
+			return false
+		}
+	}
+
+	if opts.outputFile != "" {
+		return exportMessagesToFile(ctx, convs, opts)
+	}
+
+	d, err := openOutputDir(opts.exportDir, opts.passphrase)
 	if err != nil {
 		log.Print(err)
 		return false
 	}
+	defer func() {
+		if err := d.Close(); err != nil {
+			log.Print(err)
+		}
+	}()
 
 	ret := true
 	for _, conv := range convs {
@@ -186,58 +396,259 @@ func exportMessages(ctx *signal.Context, opts *messageExportOptions) bool {
 	return ret
 }
 
-func exportConversationMessages(ctx *signal.Context, d at.Dir, conv *signal.Conversation, opts *messageExportOptions) error {
-	msgs, err := ctx.ConversationMessages(conv, opts.interval)
+func exportConversationMessages(ctx *signal.Context, d outputDir, conv *signal.Conversation, opts *messageExportOptions) error {
+	msgs, err := ctx.ConversationMessages(conv, opts.filter)
 	if err != nil {
 		return err
 	}
 
+	return exportMessageList(ctx, d, conv, msgs, opts)
+}
+
+func exportMessageList(ctx *signal.Context, d outputDir, conv *signal.Conversation, msgs []signal.Message, opts *messageExportOptions) error {
 	if len(msgs) == 0 {
 		return nil
 	}
 
-	f, err := conversationFile(d, conv, opts)
+	if opts.format == formatMaildir {
+		md, err := d.OpenDir(recipientFilename(conv.Recipient, "", opts.sanitiser))
+		if err != nil {
+			return err
+		}
+		defer md.Close()
+		return maildirWriteMessages(md, ctx, opts.timeFormat, opts.incremental, msgs)
+	}
+
+	if opts.period != periodNone {
+		return exportConversationPeriods(ctx, d, conv, msgs, opts)
+	}
+
+	flags := os.O_WRONLY | os.O_CREATE
+	if !opts.incremental {
+		flags |= os.O_EXCL
+	}
+
+	name := recipientFilename(conv.Recipient, messageFileExtension(opts), opts.sanitiser)
+	return writeMessageFile(ctx, d, name, flags, msgs, opts)
+}
+
+// exportConversationPeriods writes the messages of a conversation to separate
+// files, one for each period. In incremental mode, the files of periods
+// before the latest exported period are kept.
+func exportConversationPeriods(ctx *signal.Context, d outputDir, conv *signal.Conversation, msgs []signal.Message, opts *messageExportOptions) error {
+	cd, err := d.OpenDir(recipientFilename(conv.Recipient, "", opts.sanitiser))
+	if err != nil {
+		return err
+	}
+	defer cd.Close()
+
+	periodMsgs := make(map[string][]signal.Message)
+	for _, msg := range msgs {
+		p := opts.timeFormat.time(msg.Time(opts.filter.Time)).Format(opts.period.layout())
+		periodMsgs[p] = append(periodMsgs[p], msg)
+	}
+
+	periods := slices.Sorted(maps.Keys(periodMsgs))
+	ext := messageFileExtension(opts)
+
+	var latest string
+	if opts.incremental {
+		for _, p := range periods {
+			ok, err := cd.Exists(p + ext)
+			if err != nil {
+				return err
+			}
+			if ok {
+				latest = p
+			}
+		}
+	}
+
+	flags := os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+	if !opts.incremental {
+		flags |= os.O_EXCL
+	}
+
+	for _, p := range periods {
+		if p < latest {
+			ok, err := cd.Exists(p + ext)
+			if err != nil {
+				return err
+			}
+			if ok {
+				continue
+			}
+		}
+		if err := writeMessageFile(ctx, cd, p+ext, flags, periodMsgs[p], opts); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func writeMessageFile(ctx *signal.Context, d outputDir, name string, flags int, msgs []signal.Message, opts *messageExportOptions) error {
+	f, err := d.Create(name, flags, time.Time{})
 	if err != nil {
 		return err
 	}
 	ew := errio.NewWriter(f)
 
 	switch opts.format {
+	case formatCSV:
+		if err = csvWriteHeader(ew); err == nil {
+			err = csvWriteMessages(ew, opts.timeFormat, msgs)
+		}
 	case formatJSON:
-		err = jsonWriteMessages(ew, msgs)
+		err = jsonWriteMessages(ew, opts.attLinks, opts.redact, msgs)
 	case formatText:
-		err = textWriteMessages(ew, msgs)
+		err = textWriteMessages(ew, opts.timeFormat, opts.attLinks, msgs)
 	case formatTextShort:
-		err = textShortWriteMessages(ew, msgs)
+		err = textShortWriteMessages(ew, opts.timeFormat, msgs)
+	case formatTelegram:
+		err = telegramWriteMessages(ew, opts.timeFormat, opts.attLinks, msgs)
+	case formatSMS:
+		err = smsWriteMessages(ew, ctx, opts.timeFormat, msgs)
+	case formatMbox:
+		err = mboxWriteMessages(ew, ctx, opts.timeFormat, msgs)
+	case formatMarkdown:
+		err = markdownWriteMessages(ew, opts.timeFormat, opts.filter.Time, opts.attLinks, msgs)
+	case formatTemplate:
+		err = templateWriteMessages(ew, opts.template, opts.redact, msgs)
 	}
 
 	if err != nil {
-		f.Close()
+		f.Discard()
 		return err
 	}
 
 	return f.Close()
 }
 
-func conversationFile(d at.Dir, conv *signal.Conversation, opts *messageExportOptions) (*os.File, error) {
-	var ext string
+// exportMessagesToFile writes the messages of all conversations to a single
+// file, in chronological order.
+func exportMessagesToFile(ctx *signal.Context, convs []signal.Conversation, opts *messageExportOptions) bool {
+	ret := true
+	var msgs []signal.Message
+	for _, conv := range convs {
+		convMsgs, err := ctx.ConversationMessages(&conv, opts.filter)
+		if err != nil {
+			log.Print(err)
+			ret = false
+			continue
+		}
+		msgs = append(msgs, convMsgs...)
+	}
+
+	sort.SliceStable(msgs, func(i, j int) bool {
+		return msgs[i].Time(opts.filter.Time) < msgs[j].Time(opts.filter.Time)
+	})
+
+	d, f, err := openOutputFile(opts.outputFile, opts.incremental)
+	if err != nil {
+		log.Print(err)
+		return false
+	}
+	if d != nil {
+		defer d.Close()
+	}
+
+	var w io.Writer = f
+	var cw *cryptio.Writer
+	if opts.passphrase != nil {
+		if cw, err = cryptio.NewWriter(f, opts.passphrase); err != nil {
+			log.Print(err)
+			f.Discard()
+			return false
+		}
+		w = cw
+	}
+	ew := errio.NewWriter(w)
+
 	switch opts.format {
+	case formatCSV:
+		if err = csvWriteHeader(ew); err == nil {
+			err = csvWriteMessages(ew, opts.timeFormat, msgs)
+		}
 	case formatJSON:
-		ext = ".json"
-	case formatText, formatTextShort:
-		ext = ".txt"
+		err = jsonWriteTimeline(ew, opts.attLinks, opts.redact, msgs)
+	case formatText:
+		err = textWriteTimeline(ew, opts.timeFormat, opts.attLinks, msgs)
+	case formatTextShort:
+		err = textShortWriteTimeline(ew, opts.timeFormat, msgs)
 	}
 
-	flags := os.O_WRONLY | os.O_CREATE
-	if !opts.incremental {
+	if err == nil && cw != nil {
+		err = cw.Close()
+	}
+
+	if err != nil {
+		log.Print(err)
+		f.Discard()
+		return false
+	}
+
+	if err := f.Close(); err != nil {
+		log.Print(err)
+		return false
+	}
+
+	return ret
+}
+
+// openOutputFile opens the file specified with -o. Unless it is standard
+// output, the directory containing the file is returned as well. An existing
+// file is replaced only if replace is true.
+func openOutputFile(path string, replace bool) (outputDir, outputFile, error) {
+	if path == "-" {
+		return nil, stdoutFile{}, nil
+	}
+
+	d, err := at.Open(filepath.Dir(path))
+	if err != nil {
+		return nil, nil, err
+	}
+	dd := diskDir{d}
+
+	flags := os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+	if !replace {
 		flags |= os.O_EXCL
 	}
 
-	name := recipientFilename(conv.Recipient, ext, opts.sanitiser)
-	f, err := d.OpenFile(name, flags, 0666)
+	f, err := dd.Create(filepath.Base(path), flags, time.Time{})
 	if err != nil {
-		return nil, err
+		dd.Close()
+		return nil, nil, err
+	}
+
+	return dd, f, nil
+}
+
+// messageFileDepth returns the number of directories between the export
+// directory and the message files
+func messageFileDepth(opts *messageExportOptions) int {
+	if opts.period != periodNone {
+		// The files of each conversation are in a separate directory
+		return 1
 	}
+	return 0
+}
 
-	return f, nil
+func messageFileExtension(opts *messageExportOptions) string {
+	switch opts.format {
+	case formatCSV:
+		return ".csv"
+	case formatJSON, formatTelegram:
+		return ".json"
+	case formatMarkdown:
+		return ".md"
+	case formatMbox:
+		return ".mbox"
+	case formatSMS:
+		return ".xml"
+	case formatTemplate:
+		return opts.templateExt
+	default:
+		return ".txt"
+	}
 }
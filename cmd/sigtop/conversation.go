@@ -34,42 +34,9 @@ func selectConversations(ctx *signal.Context, selectors []string) ([]signal.Conv
 
 	var selConvs []signal.Conversation
 	for _, s := range selectors {
-		if len(s) == 0 || (len(s) == 1 && strings.IndexByte("+/=:", s[0]) >= 0) {
-			return nil, errors.New("empty conversation selector")
-		}
-		var match func(*signal.Recipient) bool
-		switch s[0] {
-		case '+':
-			match = func(r *signal.Recipient) bool {
-				return r.Type == signal.RecipientTypeContact && s == r.Contact.Phone
-			}
-		case '/':
-			re, err := regexp.Compile("(?i)" + s[1:])
-			if err != nil {
-				return nil, err
-			}
-			match = func(r *signal.Recipient) bool {
-				return re.MatchString(r.DisplayName())
-			}
-		case ':':
-			id := s[1:]
-			match = func(r *signal.Recipient) bool {
-				switch r.Type {
-				case signal.RecipientTypeContact:
-					return strings.EqualFold(id, r.Contact.ACI)
-				case signal.RecipientTypeGroup:
-					return strings.EqualFold(id, r.Group.ID)
-				default:
-					return false
-				}
-			}
-		case '=':
-			s = s[1:]
-			fallthrough
-		default:
-			match = func(r *signal.Recipient) bool {
-				return strings.EqualFold(s, r.DisplayName())
-			}
+		match, err := recipientMatcher(s)
+		if err != nil {
+			return nil, err
 		}
 
 		tmp := allConvs[:0]
@@ -85,3 +52,56 @@ func selectConversations(ctx *signal.Context, selectors []string) ([]signal.Conv
 
 	return selConvs, nil
 }
+
+func selectRecipients(ctx *signal.Context, selectors []string) ([]*signal.Recipient, error) {
+	convs, err := selectConversations(ctx, selectors)
+	if err != nil {
+		return nil, err
+	}
+
+	rpts := make([]*signal.Recipient, 0, len(convs))
+	for _, c := range convs {
+		rpts = append(rpts, c.Recipient)
+	}
+
+	return rpts, nil
+}
+
+func recipientMatcher(s string) (func(*signal.Recipient) bool, error) {
+	if len(s) == 0 || (len(s) == 1 && strings.IndexByte("+/=:", s[0]) >= 0) {
+		return nil, errors.New("empty conversation selector")
+	}
+
+	switch s[0] {
+	case '+':
+		return func(r *signal.Recipient) bool {
+			return r.Type == signal.RecipientTypeContact && s == r.Contact.Phone
+		}, nil
+	case '/':
+		re, err := regexp.Compile("(?i)" + s[1:])
+		if err != nil {
+			return nil, err
+		}
+		return func(r *signal.Recipient) bool {
+			return re.MatchString(r.DisplayName())
+		}, nil
+	case ':':
+		id := s[1:]
+		return func(r *signal.Recipient) bool {
+			switch r.Type {
+			case signal.RecipientTypeContact:
+				return strings.EqualFold(id, r.Contact.ACI)
+			case signal.RecipientTypeGroup:
+				return strings.EqualFold(id, r.Group.ID)
+			default:
+				return false
+			}
+		}, nil
+	case '=':
+		s = s[1:]
+	}
+
+	return func(r *signal.Recipient) bool {
+		return strings.EqualFold(s, r.DisplayName())
+	}, nil
+}
@@ -0,0 +1,126 @@
+// Copyright (c) 2026 Tim van der Molen <tim@kariliq.nl>
+//
+// Permission to use, copy, modify, and distribute this software for any
+// purpose with or without fee is hereby granted, provided that the above
+// copyright notice and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+// WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+// ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+// WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+// ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+// OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/tbvdm/sigtop/filename"
+	"github.com/tbvdm/sigtop/signal"
+)
+
+// A filenameTemplate specifies the path that an attachment is exported to.
+// Each slash-separated component is expanded and sanitised separately, so
+// that field values cannot introduce further components.
+type filenameTemplate struct {
+	components []string
+}
+
+// Fields of filename templates
+const filenameTemplateFields = "cdefHiMmnSsY%"
+
+func parseFilenameTemplate(s string) (*filenameTemplate, error) {
+	if s == "" {
+		return nil, fmt.Errorf("empty filename template")
+	}
+	if strings.HasPrefix(s, "/") {
+		return nil, fmt.Errorf("filename template must be a relative path: %s", s)
+	}
+
+	components := strings.Split(s, "/")
+	for _, c := range components {
+		if c == "" {
+			return nil, fmt.Errorf("filename template contains empty component: %s", s)
+		}
+		for i := 0; i < len(c); i++ {
+			if c[i] != '%' {
+				continue
+			}
+			i++
+			if i == len(c) || !strings.ContainsRune(filenameTemplateFields, rune(c[i])) {
+				return nil, fmt.Errorf("invalid field in filename template: %s", s)
+			}
+		}
+	}
+
+	return &filenameTemplate{components: components}, nil
+}
+
+// expand returns the slash-separated path of an attachment. The index is the
+// position of the attachment in its message, starting at 1.
+func (t *filenameTemplate) expand(conv *signal.Recipient, msg *signal.Message, att *signal.Attachment, index int, tf *timeFormat, sanitiser *filename.Sanitiser) (string, error) {
+	ext, err := attachmentExtension(att)
+	if err != nil {
+		return "", err
+	}
+
+	base := strings.TrimSuffix(att.FileName, filepath.Ext(att.FileName))
+	if base == "" {
+		base = "attachment"
+	}
+
+	sender := "You"
+	if !msg.IsOutgoing() {
+		sender = msg.Source.DisplayName()
+	}
+
+	sent := tf.time(att.TimeSent)
+
+	components := make([]string, len(t.components))
+	for i, c := range t.components {
+		var sb strings.Builder
+		for j := 0; j < len(c); j++ {
+			if c[j] != '%' {
+				sb.WriteByte(c[j])
+				continue
+			}
+			j++
+			switch c[j] {
+			case 'c':
+				sb.WriteString(conv.DetailedDisplayName())
+			case 'd':
+				sb.WriteString(sent.Format("02"))
+			case 'e':
+				sb.WriteString(ext)
+			case 'f':
+				sb.WriteString(base)
+			case 'H':
+				sb.WriteString(sent.Format("15"))
+			case 'i':
+				sb.WriteString(att.MessageID)
+			case 'M':
+				sb.WriteString(sent.Format("04"))
+			case 'm':
+				sb.WriteString(sent.Format("01"))
+			case 'n':
+				sb.WriteString(strconv.Itoa(index))
+			case 'S':
+				sb.WriteString(sent.Format("05"))
+			case 's':
+				sb.WriteString(sender)
+			case 'Y':
+				sb.WriteString(sent.Format("2006"))
+			case '%':
+				sb.WriteByte('%')
+			}
+		}
+		components[i] = sanitiser.Sanitise(sb.String())
+	}
+
+	return strings.Join(components, "/"), nil
+}
@@ -0,0 +1,235 @@
+// Copyright (c) 2026 Tim van der Molen <tim@kariliq.nl>
+//
+// Permission to use, copy, modify, and distribute this software for any
+// purpose with or without fee is hereby granted, provided that the above
+// copyright notice and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+// WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+// ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+// WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+// ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+// OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/tbvdm/sigtop/signal"
+)
+
+// parseMessageFilter parses message filters. The conversation selectors of
+// "from" filters are returned separately, because they can be resolved only
+// after the database has been opened.
+func parseMessageFilter(specs []string) (signal.MessageFilter, []string, error) {
+	var filter signal.MessageFilter
+	var senders []string
+
+	for _, spec := range specs {
+		key, val, found := strings.Cut(spec, ":")
+		if found && val == "" {
+			return filter, nil, fmt.Errorf("%s: empty filter value", spec)
+		}
+
+		switch {
+		case key == "from" && found:
+			// Check the selector now to catch errors early
+			if _, err := recipientMatcher(val); err != nil {
+				return filter, nil, err
+			}
+			senders = append(senders, val)
+		case key == "type" && found:
+			filter.Types = append(filter.Types, val)
+		case key == "text" && found:
+			filter.Substrings = append(filter.Substrings, val)
+		case key == "regex" && found:
+			re, err := regexp.Compile("(?i)" + val)
+			if err != nil {
+				return filter, nil, err
+			}
+			filter.Patterns = append(filter.Patterns, re)
+		case key == "attachment" && !found:
+			filter.HasAttachment = true
+		case key == "reaction" && !found:
+			filter.HasReaction = true
+		case (key == "incoming" || key == "outgoing") && !found:
+			dir := signal.DirectionIncoming
+			if key == "outgoing" {
+				dir = signal.DirectionOutgoing
+			}
+			if filter.Direction != signal.DirectionAny && filter.Direction != dir {
+				return filter, nil, errors.New("incoming and outgoing filters are mutually exclusive")
+			}
+			filter.Direction = dir
+		default:
+			return filter, nil, fmt.Errorf("%s: invalid filter", spec)
+		}
+	}
+
+	return filter, senders, nil
+}
+
+func resolveMessageFilter(ctx *signal.Context, filter *signal.MessageFilter, senders []string) error {
+	if senders == nil {
+		return nil
+	}
+	var err error
+	filter.Senders, err = selectRecipients(ctx, senders)
+	return err
+}
+
+type attachmentFilter struct {
+	types        []string
+	excludeTypes []string
+	names        []string
+	excludeNames []string
+	minSize      int64
+	maxSize      int64
+	voiceNotes   bool
+	noVoiceNotes bool
+}
+
+func parseAttachmentFilter(specs []string) (attachmentFilter, error) {
+	filter := attachmentFilter{minSize: -1, maxSize: -1}
+
+	for _, spec := range specs {
+		key, val, found := strings.Cut(spec, ":")
+		if !found {
+			return filter, fmt.Errorf("%s: invalid filter", spec)
+		}
+		if val == "" || val == "!" {
+			return filter, fmt.Errorf("%s: empty filter value", spec)
+		}
+
+		var err error
+		switch key {
+		case "type":
+			pattern, exclude := strings.CutPrefix(strings.ToLower(val), "!")
+			if _, err = path.Match(pattern, ""); err != nil {
+				break
+			}
+			if exclude {
+				filter.excludeTypes = append(filter.excludeTypes, pattern)
+			} else {
+				filter.types = append(filter.types, pattern)
+			}
+		case "name":
+			pattern, exclude := strings.CutPrefix(strings.ToLower(val), "!")
+			if _, err = path.Match(pattern, ""); err != nil {
+				break
+			}
+			if exclude {
+				filter.excludeNames = append(filter.excludeNames, pattern)
+			} else {
+				filter.names = append(filter.names, pattern)
+			}
+		case "min-size":
+			filter.minSize, err = parseSize(val)
+		case "max-size":
+			filter.maxSize, err = parseSize(val)
+		case "voice-note":
+			switch val {
+			case "yes":
+				filter.voiceNotes = true
+			case "no":
+				filter.noVoiceNotes = true
+			default:
+				err = errors.New("value must be yes or no")
+			}
+			if filter.voiceNotes && filter.noVoiceNotes {
+				err = errors.New("conflicting voice-note filters")
+			}
+		default:
+			return filter, fmt.Errorf("%s: invalid filter", spec)
+		}
+		if err != nil {
+			return filter, fmt.Errorf("%s: %w", spec, err)
+		}
+	}
+
+	if filter.minSize >= 0 && filter.maxSize >= 0 && filter.minSize > filter.maxSize {
+		return filter, errors.New("minimum size exceeds maximum size")
+	}
+
+	return filter, nil
+}
+
+func parseSize(s string) (int64, error) {
+	mult := int64(1)
+	switch s[len(s)-1] {
+	case 'k', 'K':
+		mult = 1 << 10
+	case 'm', 'M':
+		mult = 1 << 20
+	case 'g', 'G':
+		mult = 1 << 30
+	}
+	if mult > 1 {
+		s = s[:len(s)-1]
+	}
+
+	n, err := strconv.ParseInt(s, 10, 64)
+	if err != nil || n < 0 || n > (1<<63-1)/mult {
+		return 0, errors.New("invalid size")
+	}
+
+	return n * mult, nil
+}
+
+func (f *attachmentFilter) match(att *signal.Attachment) bool {
+	if f.minSize >= 0 && att.Size < f.minSize {
+		return false
+	}
+	if f.maxSize >= 0 && att.Size > f.maxSize {
+		return false
+	}
+	if f.voiceNotes && !att.IsVoiceNote() {
+		return false
+	}
+	if f.noVoiceNotes && att.IsVoiceNote() {
+		return false
+	}
+
+	contentType, _, _ := strings.Cut(att.ContentType, ";")
+	contentType = strings.ToLower(strings.TrimSpace(contentType))
+	if !matchPatterns(f.types, f.excludeTypes, contentType) {
+		return false
+	}
+
+	// An attachment without a filename is never matched by a name
+	// pattern, not even by "*"
+	if att.FileName == "" {
+		return len(f.names) == 0
+	}
+
+	return matchPatterns(f.names, f.excludeNames, strings.ToLower(att.FileName))
+}
+
+// matchPatterns reports whether s is matched by at least one of the include
+// patterns (if there are any) and by none of the exclude patterns.
+func matchPatterns(include, exclude []string, s string) bool {
+	for _, pattern := range exclude {
+		if ok, _ := path.Match(pattern, s); ok {
+			return false
+		}
+	}
+
+	if len(include) == 0 {
+		return true
+	}
+
+	for _, pattern := range include {
+		if ok, _ := path.Match(pattern, s); ok {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,125 @@
+// Copyright (c) 2026 Tim van der Molen <tim@kariliq.nl>
+//
+// Permission to use, copy, modify, and distribute this software for any
+// purpose with or without fee is hereby granted, provided that the above
+// copyright notice and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+// WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+// ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+// WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+// ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+// OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+package main
+
+import (
+	"encoding/csv"
+	"strconv"
+	"strings"
+
+	"github.com/tbvdm/sigtop/errio"
+	"github.com/tbvdm/sigtop/signal"
+)
+
+var csvHeader = []string{
+	"conversation",
+	"id",
+	"sent",
+	"received",
+	"sender",
+	"sender_id",
+	"type",
+	"body",
+	"quote_sent",
+	"attachments",
+	"attachment_filenames",
+	"reactions",
+	"edited",
+}
+
+func csvWriteHeader(ew *errio.Writer) error {
+	cw := csvNewWriter(ew)
+	cw.Write(csvHeader)
+	cw.Flush()
+	return ew.Err()
+}
+
+func csvWriteMessages(ew *errio.Writer, tf *timeFormat, msgs []signal.Message) error {
+	cw := csvNewWriter(ew)
+	for _, msg := range msgs {
+		cw.Write(csvMessageRecord(tf, &msg))
+	}
+	cw.Flush()
+	return ew.Err()
+}
+
+func csvNewWriter(ew *errio.Writer) *csv.Writer {
+	cw := csv.NewWriter(ew)
+	// RFC 4180 requires CRLF line endings
+	cw.UseCRLF = true
+	return cw
+}
+
+func csvMessageRecord(tf *timeFormat, msg *signal.Message) []string {
+	var sender, senderID string
+	if msg.Source != nil {
+		sender = msg.Source.DisplayName()
+		senderID = csvRecipientID(msg.Source)
+	}
+	if msg.IsOutgoing() {
+		sender = "You"
+	}
+
+	var quoteSent string
+	if msg.Quote != nil {
+		quoteSent = csvFormatTime(tf, msg.Quote.TimeSent)
+	}
+
+	var fileNames []string
+	for _, att := range msg.Attachments {
+		fileNames = append(fileNames, att.FileName)
+	}
+
+	var reactions []string
+	for _, rct := range msg.Reactions {
+		reactions = append(reactions, rct.Emoji+" "+rct.Recipient.DisplayName())
+	}
+
+	return []string{
+		msg.Conversation.DisplayName(),
+		msg.ID,
+		csvFormatTime(tf, msg.TimeSent),
+		csvFormatTime(tf, msg.TimeRecv),
+		sender,
+		senderID,
+		msg.Type,
+		msg.Body.Text,
+		quoteSent,
+		strconv.Itoa(len(msg.Attachments)),
+		strings.Join(fileNames, "; "),
+		strings.Join(reactions, "; "),
+		strconv.FormatBool(len(msg.Edits) > 0),
+	}
+}
+
+// csvFormatTime formats a time in ISO 8601 format, so that it can be parsed
+// by other programs. Callers must ensure the time format is an ISO 8601 one.
+func csvFormatTime(tf *timeFormat, msec int64) string {
+	if msec <= 0 {
+		return ""
+	}
+	return tf.format(msec, iso8601TimeLayout)
+}
+
+func csvRecipientID(rpt *signal.Recipient) string {
+	switch {
+	case rpt.Type == signal.RecipientTypeGroup:
+		return rpt.Group.ID
+	case rpt.Contact.ACI != "":
+		return rpt.Contact.ACI
+	default:
+		return rpt.Contact.Phone
+	}
+}
@@ -0,0 +1,254 @@
+// Copyright (c) 2026 Tim van der Molen <tim@kariliq.nl>
+//
+// Permission to use, copy, modify, and distribute this software for any
+// purpose with or without fee is hereby granted, provided that the above
+// copyright notice and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+// WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+// ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+// WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+// ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+// OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"net/textproto"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/tbvdm/sigtop/errio"
+	"github.com/tbvdm/sigtop/signal"
+)
+
+// Domain of the generated email addresses and message IDs. The .invalid
+// top-level domain is reserved by RFC 2606.
+const emailDomain = "signal.invalid"
+
+func mboxWriteMessages(ew *errio.Writer, ctx *signal.Context, tf *timeFormat, msgs []signal.Message) error {
+	for _, msg := range msgs {
+		data, err := emailFormatMessage(ctx, tf, &msg)
+		if err != nil {
+			return err
+		}
+		// Use the mboxrd format: quote "From " lines, including lines
+		// that have already been quoted
+		data = mboxFromLineRE.ReplaceAll(data, []byte(">$0"))
+		fmt.Fprintf(ew, "From %s %s\n", emailSender(&msg).Address, time.UnixMilli(msg.TimeSent).UTC().Format(time.ANSIC))
+		ew.Write(data)
+		fmt.Fprintln(ew)
+	}
+	return ew.Err()
+}
+
+var mboxFromLineRE = regexp.MustCompile(`(?m)^>*From `)
+
+func maildirWriteMessages(d outputDir, ctx *signal.Context, tf *timeFormat, incremental bool, msgs []signal.Message) error {
+	for _, sub := range []string{"cur", "new", "tmp"} {
+		if err := d.Mkdir(sub); err != nil {
+			return err
+		}
+	}
+
+	for _, msg := range msgs {
+		name := fmt.Sprintf("cur/%d.%s.sigtop:2,S", msg.TimeSent/1000, msg.ID)
+		if incremental {
+			if ok, err := d.Exists(name); err != nil {
+				return err
+			} else if ok {
+				continue
+			}
+		}
+
+		data, err := emailFormatMessage(ctx, tf, &msg)
+		if err != nil {
+			return err
+		}
+
+		f, err := d.Create(name, os.O_WRONLY|os.O_CREATE|os.O_EXCL, time.Time{})
+		if err != nil {
+			return err
+		}
+		if _, err := f.Write(data); err != nil {
+			f.Discard()
+			return err
+		}
+		if err := f.Close(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// emailFormatMessage formats a message as an RFC 5322 email message. Line
+// endings are converted to LF, as is customary for mbox and Maildir.
+func emailFormatMessage(ctx *signal.Context, tf *timeFormat, msg *signal.Message) ([]byte, error) {
+	var buf bytes.Buffer
+
+	writeHeader := func(key, value string) {
+		fmt.Fprintf(&buf, "%s: %s\r\n", key, value)
+	}
+
+	conv := msg.Conversation
+	writeHeader("From", emailSender(msg).String())
+	writeHeader("To", emailAddress(conv).String())
+	writeHeader("Date", tf.time(msg.TimeSent).Format(time.RFC1123Z))
+	writeHeader("Subject", mime.QEncoding.Encode("utf-8", "Signal conversation with "+conv.DisplayName()))
+	writeHeader("Message-ID", emailMessageID(conv, msg.TimeSent))
+	if msg.Quote != nil {
+		id := emailMessageID(conv, msg.Quote.TimeSent)
+		writeHeader("In-Reply-To", id)
+		writeHeader("References", id)
+	}
+	writeHeader("MIME-Version", "1.0")
+
+	var atts []signal.Attachment
+	for _, att := range msg.Attachments {
+		if att.Path != "" {
+			atts = append(atts, att)
+		}
+	}
+
+	if len(atts) == 0 {
+		writeHeader("Content-Type", "text/plain; charset=utf-8")
+		writeHeader("Content-Transfer-Encoding", "quoted-printable")
+		buf.WriteString("\r\n")
+		if err := emailWriteText(&buf, msg); err != nil {
+			return nil, err
+		}
+	} else {
+		mw := multipart.NewWriter(&buf)
+		writeHeader("Content-Type", mime.FormatMediaType("multipart/mixed", map[string]string{"boundary": mw.Boundary()}))
+		buf.WriteString("\r\n")
+
+		h := make(textproto.MIMEHeader)
+		h.Set("Content-Type", "text/plain; charset=utf-8")
+		h.Set("Content-Transfer-Encoding", "quoted-printable")
+		pw, err := mw.CreatePart(h)
+		if err != nil {
+			return nil, err
+		}
+		if err := emailWriteText(pw, msg); err != nil {
+			return nil, err
+		}
+
+		for _, att := range atts {
+			var data bytes.Buffer
+			if err := ctx.WriteAttachment(&att, &data); err != nil {
+				log.Printf("cannot read attachment: %v (conversation: %q, sent: %d)", err, conv.DisplayName(), msg.TimeSent)
+				continue
+			}
+			if err := emailWriteAttachment(mw, &att, data.Bytes()); err != nil {
+				return nil, err
+			}
+		}
+
+		if err := mw.Close(); err != nil {
+			return nil, err
+		}
+	}
+
+	return bytes.ReplaceAll(buf.Bytes(), []byte("\r\n"), []byte("\n")), nil
+}
+
+func emailWriteText(w io.Writer, msg *signal.Message) error {
+	var text strings.Builder
+	if qte := msg.Quote; qte != nil {
+		fmt.Fprintf(&text, "%s wrote:\n", qte.Recipient.DisplayName())
+		for _, line := range strings.Split(qte.Body.Text, "\n") {
+			fmt.Fprintln(&text, ">", line)
+		}
+		fmt.Fprintln(&text)
+	}
+	if msg.Body.Text != "" {
+		fmt.Fprintln(&text, msg.Body.Text)
+	}
+
+	qw := quotedprintable.NewWriter(w)
+	if _, err := qw.Write([]byte(text.String())); err != nil {
+		return err
+	}
+	return qw.Close()
+}
+
+func emailWriteAttachment(mw *multipart.Writer, att *signal.Attachment, data []byte) error {
+	contentType := att.ContentType
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+
+	h := make(textproto.MIMEHeader)
+	h.Set("Content-Type", contentType)
+	h.Set("Content-Transfer-Encoding", "base64")
+	if att.FileName != "" {
+		h.Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": att.FileName}))
+	} else {
+		h.Set("Content-Disposition", "attachment")
+	}
+
+	pw, err := mw.CreatePart(h)
+	if err != nil {
+		return err
+	}
+
+	// Split the base64 data into lines of at most 76 characters, as
+	// required by RFC 2045
+	enc := base64.StdEncoding.EncodeToString(data)
+	for len(enc) > 76 {
+		if _, err := fmt.Fprintf(pw, "%s\r\n", enc[:76]); err != nil {
+			return err
+		}
+		enc = enc[76:]
+	}
+	_, err = fmt.Fprintf(pw, "%s\r\n", enc)
+	return err
+}
+
+func emailSender(msg *signal.Message) *mail.Address {
+	if msg.IsOutgoing() && msg.Source == nil {
+		return &mail.Address{Name: "You", Address: "you@" + emailDomain}
+	}
+	return emailAddress(msg.Source)
+}
+
+func emailAddress(rpt *signal.Recipient) *mail.Address {
+	local := rpt.Detail()
+	if local == "" {
+		local = "unknown"
+	}
+	return &mail.Address{Name: rpt.DisplayName(), Address: local + "@" + emailDomain}
+}
+
+// emailMessageID returns a message ID based on the conversation and the time
+// the message was sent. This allows quotes, which refer to the sent time only,
+// to be linked to the quoted message. The conversation is identified by the
+// group ID or the ACI of the contact, since other details may be absent or
+// shared by several conversations.
+func emailMessageID(conv *signal.Recipient, timeSent int64) string {
+	var id string
+	switch {
+	case conv.Type == signal.RecipientTypeGroup:
+		id = conv.Group.IDHex()
+	case conv.Contact.ACI != "":
+		id = conv.Contact.ACI
+	case conv.Contact.Phone != "":
+		id = conv.Contact.Phone
+	default:
+		id = "unknown"
+	}
+	return fmt.Sprintf("<%d.%s@%s>", timeSent, id, emailDomain)
+}
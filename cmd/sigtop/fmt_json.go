@@ -15,16 +15,92 @@
 package main
 
 import (
+	"encoding/json"
 	"fmt"
+	"strings"
 
 	"github.com/tbvdm/sigtop/errio"
 	"github.com/tbvdm/sigtop/signal"
 )
 
-func jsonWriteMessages(ew *errio.Writer, msgs []signal.Message) error {
+type jsonExportedAttachment struct {
+	FileName    string `json:"fileName"`
+	ContentType string `json:"contentType"`
+	Path        string `json:"path"`
+}
+
+// Messages are written in this format when personal data is redacted. The raw
+// message data from the database cannot be redacted reliably, so only the
+// parsed fields are written. Field names follow those of Signal Desktop.
+type jsonRedactedMessage struct {
+	ID                  string                   `json:"id"`
+	ConversationName    string                   `json:"conversationName,omitempty"`
+	Type                string                   `json:"type"`
+	SourceName          string                   `json:"sourceName,omitempty"`
+	SourceServiceID     string                   `json:"sourceServiceId,omitempty"`
+	SentAt              int64                    `json:"sent_at"`
+	ReceivedAt          int64                    `json:"received_at_ms,omitempty"`
+	ServerTimestamp     int64                    `json:"serverTimestamp,omitempty"`
+	Body                string                   `json:"body,omitempty"`
+	Attachments         []jsonRedactedAttachment `json:"attachments,omitempty"`
+	Quote               *jsonRedactedQuote       `json:"quote,omitempty"`
+	Reactions           []jsonRedactedReaction   `json:"reactions,omitempty"`
+	EditHistory         []jsonRedactedEdit       `json:"editHistory,omitempty"`
+	ExportedAttachments []jsonExportedAttachment `json:"exportedAttachments,omitempty"`
+}
+
+type jsonRedactedAttachment struct {
+	FileName    string `json:"fileName,omitempty"`
+	ContentType string `json:"contentType"`
+}
+
+type jsonRedactedQuote struct {
+	ID          int64                    `json:"id"`
+	AuthorName  string                   `json:"authorName,omitempty"`
+	AuthorACI   string                   `json:"authorAci,omitempty"`
+	Text        string                   `json:"text,omitempty"`
+	Attachments []jsonRedactedAttachment `json:"attachments,omitempty"`
+}
+
+type jsonRedactedReaction struct {
+	Emoji      string `json:"emoji"`
+	FromName   string `json:"fromName,omitempty"`
+	FromID     string `json:"fromId,omitempty"`
+	Timestamp  int64  `json:"timestamp"`
+	ReceivedAt int64  `json:"receivedAtMs,omitempty"`
+}
+
+type jsonRedactedEdit struct {
+	Body        string                   `json:"body,omitempty"`
+	Attachments []jsonRedactedAttachment `json:"attachments,omitempty"`
+	Quote       *jsonRedactedQuote       `json:"quote,omitempty"`
+	Timestamp   int64                    `json:"timestamp"`
+}
+
+func jsonWriteMessages(ew *errio.Writer, links map[string]string, redact bool, msgs []signal.Message) error {
+	return jsonWriteMessageList(ew, links, false, redact, msgs)
+}
+
+// jsonWriteTimeline writes messages from multiple conversations. The name of
+// the conversation is added to each message.
+func jsonWriteTimeline(ew *errio.Writer, links map[string]string, redact bool, msgs []signal.Message) error {
+	return jsonWriteMessageList(ew, links, true, redact, msgs)
+}
+
+func jsonWriteMessageList(ew *errio.Writer, links map[string]string, withConv, redact bool, msgs []signal.Message) error {
 	fmt.Fprintln(ew, "[")
 	for i, msg := range msgs {
-		fmt.Fprint(ew, msg.JSON)
+		var data string
+		var err error
+		if redact {
+			data, err = jsonRedactedMessageData(&msg, links, withConv)
+		} else {
+			data, err = jsonMessage(&msg, links, withConv)
+		}
+		if err != nil {
+			return err
+		}
+		fmt.Fprint(ew, data)
 		if i+1 < len(msgs) {
 			fmt.Fprint(ew, ",")
 		}
@@ -33,3 +109,150 @@ func jsonWriteMessages(ew *errio.Writer, msgs []signal.Message) error {
 	fmt.Fprintln(ew, "]")
 	return ew.Err()
 }
+
+// jsonMessage returns the JSON data of a message. If any attachments of the
+// message have been exported, their paths are added in the
+// "exportedAttachments" field.
+func jsonMessage(msg *signal.Message, links map[string]string, withConv bool) (string, error) {
+	data := msg.JSON
+	var err error
+
+	if withConv {
+		if data, err = jsonAddField(data, "conversationName", msg.Conversation.DisplayName()); err != nil {
+			return "", err
+		}
+	}
+
+	if atts := jsonExportedAttachments(msg, links); len(atts) > 0 {
+		if data, err = jsonAddField(data, "exportedAttachments", atts); err != nil {
+			return "", err
+		}
+	}
+
+	return data, nil
+}
+
+// jsonRedactedMessageData returns the JSON data of a message in the format
+// used when personal data is redacted
+func jsonRedactedMessageData(msg *signal.Message, links map[string]string, withConv bool) (string, error) {
+	jmsg := jsonRedactedMessage{
+		ID:                  msg.ID,
+		Type:                msg.Type,
+		SentAt:              msg.TimeSent,
+		ReceivedAt:          msg.TimeRecv,
+		ServerTimestamp:     msg.TimeServer,
+		Body:                msg.Body.Text,
+		Attachments:         jsonRedactedAttachments(msg.Attachments),
+		Quote:               jsonRedactedQuoteData(msg.Quote),
+		ExportedAttachments: jsonExportedAttachments(msg, links),
+	}
+
+	if withConv {
+		jmsg.ConversationName = msg.Conversation.DisplayName()
+	}
+
+	if msg.Source != nil {
+		jmsg.SourceName = msg.Source.DisplayName()
+		jmsg.SourceServiceID = msg.Source.Contact.ACI
+	}
+
+	for _, rct := range msg.Reactions {
+		jrct := jsonRedactedReaction{
+			Emoji:      rct.Emoji,
+			Timestamp:  rct.TimeSent,
+			ReceivedAt: rct.TimeRecv,
+		}
+		if rct.Recipient != nil {
+			jrct.FromName = rct.Recipient.DisplayName()
+			jrct.FromID = rct.Recipient.Contact.ACI
+		}
+		jmsg.Reactions = append(jmsg.Reactions, jrct)
+	}
+
+	for _, edit := range msg.Edits {
+		jmsg.EditHistory = append(jmsg.EditHistory, jsonRedactedEdit{
+			Body:        edit.Body.Text,
+			Attachments: jsonRedactedAttachments(edit.Attachments),
+			Quote:       jsonRedactedQuoteData(edit.Quote),
+			Timestamp:   edit.TimeEdit,
+		})
+	}
+
+	data, err := json.Marshal(jmsg)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+func jsonRedactedAttachments(atts []signal.Attachment) []jsonRedactedAttachment {
+	var jatts []jsonRedactedAttachment
+	for _, att := range atts {
+		jatts = append(jatts, jsonRedactedAttachment{
+			FileName:    att.FileName,
+			ContentType: att.ContentType,
+		})
+	}
+	return jatts
+}
+
+func jsonRedactedQuoteData(qte *signal.Quote) *jsonRedactedQuote {
+	if qte == nil {
+		return nil
+	}
+
+	jqte := &jsonRedactedQuote{
+		ID:   qte.TimeSent,
+		Text: qte.Body.Text,
+	}
+
+	if qte.Recipient != nil {
+		jqte.AuthorName = qte.Recipient.DisplayName()
+		jqte.AuthorACI = qte.Recipient.Contact.ACI
+	}
+
+	for _, att := range qte.Attachments {
+		jqte.Attachments = append(jqte.Attachments, jsonRedactedAttachment{
+			FileName:    att.FileName,
+			ContentType: att.ContentType,
+		})
+	}
+
+	return jqte
+}
+
+// jsonExportedAttachments returns the exported attachments of a message
+func jsonExportedAttachments(msg *signal.Message, links map[string]string) []jsonExportedAttachment {
+	var atts []jsonExportedAttachment
+	for _, att := range msg.Attachments {
+		if link, ok := links[attachmentID(&att)]; ok && att.Path != "" {
+			atts = append(atts, jsonExportedAttachment{
+				FileName:    att.FileName,
+				ContentType: att.ContentType,
+				Path:        link,
+			})
+		}
+	}
+	return atts
+}
+
+// jsonAddField adds a field to a JSON object, without otherwise changing its
+// encoding.
+func jsonAddField(obj, key string, value any) (string, error) {
+	obj = strings.TrimRight(obj, " \t\r\n")
+	if !strings.HasSuffix(obj, "}") {
+		return "", fmt.Errorf("cannot add field %q: not a JSON object", key)
+	}
+
+	field, err := json.Marshal(map[string]any{key: value})
+	if err != nil {
+		return "", err
+	}
+	field = field[1 : len(field)-1]
+
+	obj = strings.TrimRight(obj[:len(obj)-1], " \t\r\n")
+	if strings.HasSuffix(obj, "{") {
+		return obj + string(field) + "}", nil
+	}
+	return obj + "," + string(field) + "}", nil
+}
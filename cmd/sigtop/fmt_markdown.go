@@ -0,0 +1,211 @@
+// Copyright (c) 2026 Tim van der Molen <tim@kariliq.nl>
+//
+// Permission to use, copy, modify, and distribute this software for any
+// purpose with or without fee is hereby granted, provided that the above
+// copyright notice and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+// WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+// ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+// WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+// ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+// OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/tbvdm/sigtop/errio"
+	"github.com/tbvdm/sigtop/signal"
+)
+
+const (
+	markdownDateLayout = "Monday, 2 January 2006"
+	markdownTimeLayout = "15:04"
+)
+
+type markdownWriter struct {
+	ew      *errio.Writer
+	tf      *timeFormat
+	msgTime signal.MessageTime
+	links   map[string]string
+}
+
+func markdownWriteMessages(ew *errio.Writer, tf *timeFormat, msgTime signal.MessageTime, links map[string]string, msgs []signal.Message) error {
+	mw := markdownWriter{ew: ew, tf: tf, msgTime: msgTime, links: links}
+
+	fmt.Fprintf(ew, "# %s\n", markdownEscape(msgs[0].Conversation.DetailedDisplayName()))
+	var day string
+	for _, msg := range msgs {
+		if d := tf.time(msg.Time(msgTime)).Format(markdownDateLayout); d != day {
+			day = d
+			fmt.Fprintf(ew, "\n## %s\n", day)
+		}
+		mw.writeMessage(&msg)
+	}
+	return ew.Err()
+}
+
+func (mw *markdownWriter) writeMessage(msg *signal.Message) {
+	var sender string
+	switch {
+	case msg.IsOutgoing():
+		sender = "You"
+	case msg.Source != nil:
+		sender = msg.Source.DetailedDisplayName()
+	default:
+		sender = "Unknown"
+	}
+
+	fmt.Fprintf(mw.ew, "\n**%s**, %s", markdownEscape(sender), mw.tf.format(msg.Time(mw.msgTime), markdownTimeLayout))
+	if msg.Type != "incoming" && msg.Type != "outgoing" {
+		typ := msg.Type
+		if typ == "" {
+			typ = "unknown"
+		}
+		fmt.Fprintf(mw.ew, " (%s)", markdownEscape(typ))
+	}
+	fmt.Fprintln(mw.ew)
+
+	if len(msg.Edits) == 0 {
+		mw.writeQuote(msg.Quote)
+		mw.writeBody("", &msg.Body)
+		mw.writeAttachments("", msg.Attachments)
+	} else {
+		mw.writeQuote(msg.Edits[0].Quote)
+		mw.writeBody("", &msg.Edits[0].Body)
+		mw.writeAttachments("", msg.Edits[0].Attachments)
+		mw.writeEditHistory(msg.Edits)
+	}
+
+	if len(msg.Reactions) > 0 {
+		fmt.Fprintln(mw.ew)
+		fmt.Fprintln(mw.ew, "- Reactions:")
+		for _, rct := range msg.Reactions {
+			fmt.Fprintf(mw.ew, "  - %s %s\n", rct.Emoji, markdownEscape(rct.Recipient.DisplayName()))
+		}
+	}
+}
+
+func (mw *markdownWriter) writeQuote(qte *signal.Quote) {
+	if qte == nil {
+		return
+	}
+	fmt.Fprintln(mw.ew)
+	fmt.Fprintf(mw.ew, "> **%s**, %s\n", markdownEscape(qte.Recipient.DisplayName()), mw.tf.format(qte.TimeSent, textShortTimeLayout))
+	for _, att := range qte.Attachments {
+		fmt.Fprintf(mw.ew, "> *Attachment: %s*\n", markdownEscape(markdownAttachmentName(att.FileName, att.ContentType)))
+	}
+	mw.writeBody(">", &qte.Body)
+}
+
+func (mw *markdownWriter) writeBody(prefix string, body *signal.MessageBody) {
+	if body.Text == "" {
+		return
+	}
+	fmt.Fprintln(mw.ew, prefix)
+	if prefix != "" {
+		prefix += " "
+	}
+	lines := strings.Split(body.Text, "\n")
+	for i, line := range lines {
+		fmt.Fprint(mw.ew, prefix+markdownEscapeLine(line))
+		if i+1 < len(lines) && line != "" && lines[i+1] != "" {
+			// Hard line break
+			fmt.Fprint(mw.ew, "\\")
+		}
+		fmt.Fprintln(mw.ew)
+	}
+}
+
+func (mw *markdownWriter) writeAttachments(indent string, atts []signal.Attachment) {
+	if len(atts) == 0 {
+		return
+	}
+	if indent == "" {
+		fmt.Fprintln(mw.ew)
+	}
+	for _, att := range atts {
+		name := markdownEscape(markdownAttachmentName(att.FileName, att.ContentType))
+		link, ok := mw.links[attachmentID(&att)]
+		switch {
+		case !ok || att.Path == "":
+			fmt.Fprintf(mw.ew, "%s- Attachment: %s (%s, %d bytes)\n", indent, name, att.ContentType, att.Size)
+		case strings.HasPrefix(att.ContentType, "image/"):
+			fmt.Fprintf(mw.ew, "%s- ![%s](%s)\n", indent, name, markdownEscapeLink(link))
+		default:
+			fmt.Fprintf(mw.ew, "%s- Attachment: [%s](%s)\n", indent, name, markdownEscapeLink(link))
+		}
+	}
+}
+
+func (mw *markdownWriter) writeEditHistory(edits []signal.Edit) {
+	fmt.Fprintln(mw.ew)
+	fmt.Fprintf(mw.ew, "- Edited, %d versions:\n", len(edits))
+	for i := range edits {
+		fmt.Fprintf(mw.ew, "  - Version %d, %s", len(edits)-i, mw.tf.format(edits[i].TimeEdit, textShortTimeLayout))
+		if edits[i].Body.Text != "" {
+			fmt.Fprint(mw.ew, ":")
+		}
+		fmt.Fprintln(mw.ew)
+		for _, line := range strings.Split(edits[i].Body.Text, "\n") {
+			if line != "" {
+				fmt.Fprintf(mw.ew, "    %s\n", markdownEscapeLine(line))
+			}
+		}
+		mw.writeAttachments("    ", edits[i].Attachments)
+	}
+}
+
+func markdownAttachmentName(fileName, contentType string) string {
+	if fileName != "" {
+		return fileName
+	}
+	if contentType != "" {
+		return contentType
+	}
+	return "no filename"
+}
+
+// markdownEscape escapes characters that may have a special meaning anywhere
+// in a line.
+func markdownEscape(s string) string {
+	var sb strings.Builder
+	for _, r := range s {
+		if strings.ContainsRune("\\`*_[]<>|~", r) {
+			sb.WriteByte('\\')
+		}
+		sb.WriteRune(r)
+	}
+	return sb.String()
+}
+
+// markdownEscapeLine is like markdownEscape, but also escapes characters that
+// have a special meaning at the start of a line. Leading whitespace is
+// removed, so that indented lines are not written as code blocks.
+func markdownEscapeLine(s string) string {
+	s = strings.TrimLeft(markdownEscape(s), " \t")
+
+	if s != "" && strings.ContainsRune("#-+=>", rune(s[0])) {
+		return "\\" + s
+	}
+
+	// Ordered list item
+	if i := strings.IndexFunc(s, func(r rune) bool { return r < '0' || r > '9' }); i > 0 && (s[i] == '.' || s[i] == ')') {
+		return s[:i] + "\\" + s[i:]
+	}
+
+	return s
+}
+
+func markdownEscapeLink(link string) string {
+	segs := strings.Split(link, "/")
+	for i := range segs {
+		segs[i] = url.PathEscape(segs[i])
+	}
+	return strings.Join(segs, "/")
+}
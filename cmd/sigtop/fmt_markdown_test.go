@@ -0,0 +1,42 @@
+// Copyright (c) 2026 Tim van der Molen <tim@kariliq.nl>
+//
+// Permission to use, copy, modify, and distribute this software for any
+// purpose with or without fee is hereby granted, provided that the above
+// copyright notice and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+// WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+// ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+// WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+// ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+// OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+package main
+
+import "testing"
+
+func TestMarkdownEscapeLine(t *testing.T) {
+	tests := []struct {
+		in, out string
+	}{
+		{"plain text", "plain text"},
+		{"# heading", "\\# heading"},
+		{"- item", "\\- item"},
+		{"12. item", "12\\. item"},
+		{"1) item", "1\\) item"},
+		{"2024 was a year", "2024 was a year"},
+		{"  > quote", "\\> quote"},
+		{"    code", "code"},
+		{"\tcode", "code"},
+		{" \t - item", "\\- item"},
+		{"    ", ""},
+		{"*bold*", "\\*bold\\*"},
+	}
+
+	for _, test := range tests {
+		if out := markdownEscapeLine(test.in); out != test.out {
+			t.Errorf("markdownEscapeLine(%q) = %q, want %q", test.in, out, test.out)
+		}
+	}
+}
@@ -0,0 +1,290 @@
+// Copyright (c) 2026 Tim van der Molen <tim@kariliq.nl>
+//
+// Permission to use, copy, modify, and distribute this software for any
+// purpose with or without fee is hereby granted, provided that the above
+// copyright notice and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+// WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+// ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+// WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+// ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+// OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/xml"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+
+	"github.com/tbvdm/sigtop/errio"
+	"github.com/tbvdm/sigtop/signal"
+)
+
+// This file implements the XML format of the SMS Backup & Restore app for
+// Android.
+
+const smsReadableDateLayout = "Jan 2, 2006 3:04:05 PM"
+
+// Values of the type attribute of sms elements and the msg_box attribute of
+// mms elements
+const (
+	smsTypeReceived = 1
+	smsTypeSent     = 2
+)
+
+// Values of the m_type attribute of mms elements
+const (
+	mmsTypeSendReq      = 128
+	mmsTypeRetrieveConf = 132
+)
+
+// Values of the type attribute of addr elements
+const (
+	mmsAddrTypeFrom = 137
+	mmsAddrTypeTo   = 151
+)
+
+// Character set number of UTF-8
+const mmsCharsetUTF8 = 106
+
+type smsXML struct {
+	XMLName       xml.Name `xml:"sms"`
+	Protocol      int      `xml:"protocol,attr"`
+	Address       string   `xml:"address,attr"`
+	Date          int64    `xml:"date,attr"`
+	Type          int      `xml:"type,attr"`
+	Subject       string   `xml:"subject,attr"`
+	Body          string   `xml:"body,attr"`
+	ServiceCenter string   `xml:"service_center,attr"`
+	Read          int      `xml:"read,attr"`
+	Status        int      `xml:"status,attr"`
+	Locked        int      `xml:"locked,attr"`
+	DateSent      int64    `xml:"date_sent,attr"`
+	ReadableDate  string   `xml:"readable_date,attr"`
+	ContactName   string   `xml:"contact_name,attr"`
+}
+
+type mmsXML struct {
+	XMLName      xml.Name     `xml:"mms"`
+	Date         int64        `xml:"date,attr"`
+	DateSent     int64        `xml:"date_sent,attr"`
+	MsgBox       int          `xml:"msg_box,attr"`
+	Address      string       `xml:"address,attr"`
+	MType        int          `xml:"m_type,attr"`
+	Read         int          `xml:"read,attr"`
+	Locked       int          `xml:"locked,attr"`
+	TextOnly     int          `xml:"text_only,attr"`
+	ReadableDate string       `xml:"readable_date,attr"`
+	ContactName  string       `xml:"contact_name,attr"`
+	Parts        []mmsPartXML `xml:"parts>part"`
+	Addrs        []mmsAddrXML `xml:"addrs>addr"`
+}
+
+type mmsPartXML struct {
+	Seq         int    `xml:"seq,attr"`
+	ContentType string `xml:"ct,attr"`
+	Name        string `xml:"name,attr"`
+	Charset     string `xml:"chset,attr"`
+	ContentID   string `xml:"cid,attr"`
+	ContentLoc  string `xml:"cl,attr"`
+	Text        string `xml:"text,attr"`
+	Data        string `xml:"data,attr,omitempty"`
+}
+
+type mmsAddrXML struct {
+	Address string `xml:"address,attr"`
+	Type    int    `xml:"type,attr"`
+	Charset int    `xml:"charset,attr"`
+}
+
+func smsWriteMessages(ew *errio.Writer, ctx *signal.Context, tf *timeFormat, msgs []signal.Message) error {
+	conv := msgs[0].Conversation
+	addrs := smsConversationAddresses(conv, msgs)
+
+	var count int
+	for _, msg := range msgs {
+		if smsIsConvertible(&msg) {
+			count++
+		}
+	}
+
+	fmt.Fprint(ew, xml.Header)
+	enc := xml.NewEncoder(ew)
+	enc.Indent("", "  ")
+	start := xml.StartElement{
+		Name: xml.Name{Local: "smses"},
+		Attr: []xml.Attr{{Name: xml.Name{Local: "count"}, Value: strconv.Itoa(count)}},
+	}
+	if err := enc.EncodeToken(start); err != nil {
+		return err
+	}
+	for _, msg := range msgs {
+		if !smsIsConvertible(&msg) {
+			continue
+		}
+		var elem any
+		if conv.Type == signal.RecipientTypeContact && len(msg.Attachments) == 0 {
+			elem = smsMessage(tf, &msg)
+		} else {
+			elem = mmsMessage(ctx, tf, addrs, &msg)
+		}
+		if err := enc.Encode(elem); err != nil {
+			return err
+		}
+	}
+	if err := enc.EncodeToken(start.End()); err != nil {
+		return err
+	}
+	if err := enc.Close(); err != nil {
+		return err
+	}
+	fmt.Fprintln(ew)
+
+	return ew.Err()
+}
+
+func smsMessage(tf *timeFormat, msg *signal.Message) *smsXML {
+	typ := smsTypeReceived
+	if msg.IsOutgoing() {
+		typ = smsTypeSent
+	}
+
+	return &smsXML{
+		Address:       smsAddress(msg.Conversation),
+		Date:          smsDate(msg),
+		Type:          typ,
+		Subject:       "null",
+		Body:          msg.Body.Text,
+		ServiceCenter: "null",
+		Read:          1,
+		Status:        -1,
+		DateSent:      msg.TimeSent,
+		ReadableDate:  tf.format(smsDate(msg), smsReadableDateLayout),
+		ContactName:   msg.Conversation.DisplayName(),
+	}
+}
+
+func mmsMessage(ctx *signal.Context, tf *timeFormat, addrs []string, msg *signal.Message) *mmsXML {
+	mms := mmsXML{
+		Date:         smsDate(msg),
+		DateSent:     msg.TimeSent / 1000,
+		Address:      strings.Join(addrs, "~"),
+		Read:         1,
+		ReadableDate: tf.format(smsDate(msg), smsReadableDateLayout),
+		ContactName:  msg.Conversation.DisplayName(),
+	}
+
+	var sender string
+	if msg.IsOutgoing() {
+		mms.MsgBox = smsTypeSent
+		mms.MType = mmsTypeSendReq
+	} else {
+		mms.MsgBox = smsTypeReceived
+		mms.MType = mmsTypeRetrieveConf
+		if msg.Source != nil {
+			sender = smsAddress(msg.Source)
+			mms.Addrs = append(mms.Addrs, mmsAddrXML{Address: sender, Type: mmsAddrTypeFrom, Charset: mmsCharsetUTF8})
+		}
+	}
+	for _, addr := range addrs {
+		if addr != sender {
+			mms.Addrs = append(mms.Addrs, mmsAddrXML{Address: addr, Type: mmsAddrTypeTo, Charset: mmsCharsetUTF8})
+		}
+	}
+
+	if msg.Body.Text != "" {
+		mms.Parts = append(mms.Parts, mmsPartXML{
+			ContentType: "text/plain",
+			Name:        "null",
+			Charset:     strconv.Itoa(mmsCharsetUTF8),
+			ContentID:   "<text>",
+			ContentLoc:  "text.txt",
+			Text:        msg.Body.Text,
+		})
+	}
+
+	for _, att := range msg.Attachments {
+		if att.Path == "" {
+			continue
+		}
+		var data bytes.Buffer
+		if err := ctx.WriteAttachment(&att, &data); err != nil {
+			log.Printf("cannot read attachment: %v (conversation: %q, sent: %d)", err, msg.Conversation.DisplayName(), msg.TimeSent)
+			continue
+		}
+		name := att.FileName
+		if name == "" {
+			name = "attachment-" + strconv.Itoa(len(mms.Parts))
+		}
+		contentType := att.ContentType
+		if contentType == "" {
+			contentType = "application/octet-stream"
+		}
+		mms.Parts = append(mms.Parts, mmsPartXML{
+			ContentType: contentType,
+			Name:        name,
+			Charset:     "null",
+			ContentID:   "<" + name + ">",
+			ContentLoc:  name,
+			Text:        "null",
+			Data:        base64.StdEncoding.EncodeToString(data.Bytes()),
+		})
+	}
+
+	if len(mms.Parts) == 1 && mms.Parts[0].ContentType == "text/plain" {
+		mms.TextOnly = 1
+	}
+
+	return &mms
+}
+
+// smsIsConvertible reports whether a message can be converted to an SMS or
+// MMS message.
+func smsIsConvertible(msg *signal.Message) bool {
+	return msg.Type == "incoming" || msg.Type == "outgoing"
+}
+
+// smsDate returns the time a message was received or, for outgoing messages,
+// sent.
+func smsDate(msg *signal.Message) int64 {
+	if msg.IsOutgoing() {
+		return msg.TimeSent
+	}
+	return msg.TimeRecv
+}
+
+func smsAddress(rpt *signal.Recipient) string {
+	if rpt.Contact.Phone != "" {
+		return rpt.Contact.Phone
+	}
+	return rpt.Detail()
+}
+
+// smsConversationAddresses returns the addresses of the participants of a
+// conversation. For groups, the members are approximated by the senders of the
+// messages.
+func smsConversationAddresses(conv *signal.Recipient, msgs []signal.Message) []string {
+	if conv.Type == signal.RecipientTypeContact {
+		return []string{smsAddress(conv)}
+	}
+
+	var addrs []string
+	seen := make(map[string]bool)
+	for _, msg := range msgs {
+		if msg.IsOutgoing() || msg.Source == nil {
+			continue
+		}
+		if addr := smsAddress(msg.Source); !seen[addr] {
+			seen[addr] = true
+			addrs = append(addrs, addr)
+		}
+	}
+	return addrs
+}
@@ -0,0 +1,319 @@
+// Copyright (c) 2026 Tim van der Molen <tim@kariliq.nl>
+//
+// Permission to use, copy, modify, and distribute this software for any
+// purpose with or without fee is hereby granted, provided that the above
+// copyright notice and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+// WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+// ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+// WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+// ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+// OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+package main
+
+import (
+	"encoding/json"
+	"hash/fnv"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/tbvdm/sigtop/errio"
+	"github.com/tbvdm/sigtop/signal"
+)
+
+// This file implements the result.json format of Telegram Desktop exports.
+
+const (
+	telegramDateLayout  = "2006-01-02T15:04:05"
+	telegramFileMissing = "(File not included. Change data exporting settings to download.)"
+)
+
+type telegramChat struct {
+	Name     string            `json:"name"`
+	Type     string            `json:"type"`
+	ID       int64             `json:"id"`
+	Messages []telegramMessage `json:"messages"`
+}
+
+type telegramMessage struct {
+	ID               int                `json:"id"`
+	Type             string             `json:"type"`
+	Date             string             `json:"date"`
+	DateUnixtime     string             `json:"date_unixtime"`
+	Edited           string             `json:"edited,omitempty"`
+	EditedUnixtime   string             `json:"edited_unixtime,omitempty"`
+	From             string             `json:"from,omitempty"`
+	FromID           string             `json:"from_id,omitempty"`
+	Actor            string             `json:"actor,omitempty"`
+	ActorID          string             `json:"actor_id,omitempty"`
+	Action           string             `json:"action,omitempty"`
+	ReplyToMessageID int                `json:"reply_to_message_id,omitempty"`
+	Photo            string             `json:"photo,omitempty"`
+	File             string             `json:"file,omitempty"`
+	FileName         string             `json:"file_name,omitempty"`
+	MediaType        string             `json:"media_type,omitempty"`
+	MimeType         string             `json:"mime_type,omitempty"`
+	Text             any                `json:"text"`
+	TextEntities     []telegramEntity   `json:"text_entities"`
+	Reactions        []telegramReaction `json:"reactions,omitempty"`
+}
+
+type telegramEntity struct {
+	Type   string `json:"type"`
+	Text   string `json:"text"`
+	UserID int64  `json:"user_id,omitempty"`
+}
+
+type telegramReaction struct {
+	Type   string                   `json:"type"`
+	Count  int                      `json:"count"`
+	Emoji  string                   `json:"emoji"`
+	Recent []telegramReactionSender `json:"recent"`
+}
+
+type telegramReactionSender struct {
+	From   string `json:"from"`
+	FromID string `json:"from_id"`
+	Date   string `json:"date"`
+}
+
+func telegramWriteMessages(ew *errio.Writer, tf *timeFormat, links map[string]string, msgs []signal.Message) error {
+	conv := msgs[0].Conversation
+	chat := telegramChat{
+		Name:     conv.DisplayName(),
+		Type:     "personal_chat",
+		ID:       telegramRecipientID(conv),
+		Messages: []telegramMessage{},
+	}
+	if conv.Type == signal.RecipientTypeGroup {
+		chat.Type = "private_group"
+	}
+
+	// Quotes refer to the time the quoted message was sent
+	ids := make(map[int64]int)
+
+	for _, msg := range msgs {
+		tmsg := telegramMessage{
+			ID:           len(chat.Messages) + 1,
+			Type:         "message",
+			Date:         tf.time(msg.TimeSent).Format(telegramDateLayout),
+			DateUnixtime: strconv.FormatInt(msg.TimeSent/1000, 10),
+		}
+		ids[msg.TimeSent] = tmsg.ID
+
+		from, fromID := telegramSender(&msg)
+		if msg.Type == "incoming" || msg.Type == "outgoing" {
+			tmsg.From, tmsg.FromID = from, fromID
+		} else {
+			tmsg.Type = "service"
+			tmsg.Actor, tmsg.ActorID = from, fromID
+			tmsg.Action = msg.Type
+		}
+
+		if len(msg.Edits) > 0 {
+			tmsg.Edited = tf.time(msg.Edits[0].TimeEdit).Format(telegramDateLayout)
+			tmsg.EditedUnixtime = strconv.FormatInt(msg.Edits[0].TimeEdit/1000, 10)
+		}
+
+		if msg.Quote != nil {
+			tmsg.ReplyToMessageID = ids[msg.Quote.TimeSent]
+		}
+
+		tmsg.Text, tmsg.TextEntities = telegramText(&msg.Body)
+		tmsg.Reactions = telegramReactions(tf, msg.Reactions)
+
+		// Telegram messages have at most one media file, so add a
+		// separate message for every further attachment
+		for i, att := range msg.Attachments {
+			if i > 0 {
+				tmsg = telegramMessage{
+					ID:           len(chat.Messages) + 1,
+					Type:         tmsg.Type,
+					Date:         tmsg.Date,
+					DateUnixtime: tmsg.DateUnixtime,
+					From:         tmsg.From,
+					FromID:       tmsg.FromID,
+					Text:         "",
+					TextEntities: []telegramEntity{},
+				}
+			}
+			telegramSetMedia(&tmsg, &att, links)
+			chat.Messages = append(chat.Messages, tmsg)
+		}
+		if len(msg.Attachments) == 0 {
+			chat.Messages = append(chat.Messages, tmsg)
+		}
+	}
+
+	enc := json.NewEncoder(ew)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", " ")
+	if err := enc.Encode(&chat); err != nil {
+		return err
+	}
+
+	return ew.Err()
+}
+
+func telegramSender(msg *signal.Message) (string, string) {
+	if msg.IsOutgoing() && msg.Source == nil {
+		return "You", "user0"
+	}
+	return msg.Source.DisplayName(), "user" + strconv.FormatInt(telegramRecipientID(msg.Source), 10)
+}
+
+// telegramRecipientID derives a numeric ID from a recipient, because Telegram
+// tools expect numeric user and chat IDs.
+func telegramRecipientID(rpt *signal.Recipient) int64 {
+	h := fnv.New32a()
+	h.Write([]byte(rpt.Detail()))
+	return int64(h.Sum32())
+}
+
+func telegramSetMedia(tmsg *telegramMessage, att *signal.Attachment, links map[string]string) {
+	link, ok := links[attachmentID(att)]
+	if !ok || att.Path == "" {
+		link = telegramFileMissing
+	}
+
+	contentType := strings.ToLower(att.ContentType)
+	if strings.HasPrefix(contentType, "image/") && contentType != "image/gif" {
+		tmsg.Photo = link
+		return
+	}
+
+	tmsg.File = link
+	tmsg.FileName = att.FileName
+	tmsg.MimeType = att.ContentType
+	switch {
+	case att.IsVoiceNote():
+		tmsg.MediaType = "voice_message"
+	case contentType == "image/gif":
+		tmsg.MediaType = "animation"
+	case strings.HasPrefix(contentType, "video/"):
+		tmsg.MediaType = "video_file"
+	case strings.HasPrefix(contentType, "audio/"):
+		tmsg.MediaType = "audio_file"
+	}
+}
+
+// telegramText converts a message body to Telegram text entities. Telegram
+// entities cannot overlap, so if style ranges and mentions overlap, the text
+// is split into segments and each segment is given a single type.
+func telegramText(body *signal.MessageBody) (any, []telegramEntity) {
+	if body.Text == "" {
+		return "", []telegramEntity{}
+	}
+
+	bounds := []int{0, len(body.Text)}
+	for _, mnt := range body.Mentions {
+		bounds = append(bounds, mnt.Start, mnt.Start+mnt.Length)
+	}
+	for _, sty := range body.Styles {
+		bounds = append(bounds, sty.Start, sty.Start+sty.Length)
+	}
+	slices.Sort(bounds)
+	bounds = slices.Compact(bounds)
+
+	var ents []telegramEntity
+	for i := 0; i+1 < len(bounds); i++ {
+		start, end := bounds[i], bounds[i+1]
+		if start < 0 || end > len(body.Text) {
+			continue
+		}
+		ent := telegramEntity{Type: "plain", Text: body.Text[start:end]}
+
+		for _, mnt := range body.Mentions {
+			if mnt.Start <= start && end <= mnt.Start+mnt.Length {
+				ent.Type = "mention_name"
+				ent.UserID = telegramRecipientID(mnt.Recipient)
+			}
+		}
+		if ent.Type == "plain" {
+			best := signal.StyleNone
+			for _, sty := range body.Styles {
+				if sty.Start <= start && end <= sty.Start+sty.Length && telegramStylePriority(sty.Style) > telegramStylePriority(best) {
+					best = sty.Style
+				}
+			}
+			ent.Type = telegramStyleType(best)
+		}
+
+		// Merge adjacent plain segments
+		if n := len(ents); n > 0 && ent.Type == "plain" && ents[n-1].Type == "plain" {
+			ents[n-1].Text += ent.Text
+		} else {
+			ents = append(ents, ent)
+		}
+	}
+
+	if len(ents) == 1 && ents[0].Type == "plain" {
+		return ents[0].Text, ents
+	}
+
+	text := make([]any, len(ents))
+	for i, ent := range ents {
+		if ent.Type == "plain" {
+			text[i] = ent.Text
+		} else {
+			text[i] = ent
+		}
+	}
+	return text, ents
+}
+
+func telegramStylePriority(sty signal.Style) int {
+	switch sty {
+	case signal.StyleMonospace:
+		return 5
+	case signal.StyleBold:
+		return 4
+	case signal.StyleItalic:
+		return 3
+	case signal.StyleStrikethrough:
+		return 2
+	case signal.StyleSpoiler:
+		return 1
+	default:
+		return 0
+	}
+}
+
+func telegramStyleType(sty signal.Style) string {
+	switch sty {
+	case signal.StyleBold:
+		return "bold"
+	case signal.StyleItalic:
+		return "italic"
+	case signal.StyleSpoiler:
+		return "spoiler"
+	case signal.StyleStrikethrough:
+		return "strikethrough"
+	case signal.StyleMonospace:
+		return "code"
+	default:
+		return "plain"
+	}
+}
+
+func telegramReactions(tf *timeFormat, rcts []signal.Reaction) []telegramReaction {
+	var trcts []telegramReaction
+	for _, rct := range rcts {
+		i := slices.IndexFunc(trcts, func(trct telegramReaction) bool { return trct.Emoji == rct.Emoji })
+		if i < 0 {
+			trcts = append(trcts, telegramReaction{Type: "emoji", Emoji: rct.Emoji})
+			i = len(trcts) - 1
+		}
+		trcts[i].Count++
+		trcts[i].Recent = append(trcts[i].Recent, telegramReactionSender{
+			From:   rct.Recipient.DisplayName(),
+			FromID: "user" + strconv.FormatInt(telegramRecipientID(rct.Recipient), 10),
+			Date:   tf.time(rct.TimeSent).Format(telegramDateLayout),
+		})
+	}
+	return trcts
+}
@@ -0,0 +1,127 @@
+// Copyright (c) 2026 Tim van der Molen <tim@kariliq.nl>
+//
+// Permission to use, copy, modify, and distribute this software for any
+// purpose with or without fee is hereby granted, provided that the above
+// copyright notice and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+// WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+// ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+// WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+// ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+// OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"text/template"
+
+	"github.com/tbvdm/sigtop/errio"
+	"github.com/tbvdm/sigtop/signal"
+)
+
+type templateData struct {
+	Conversation *signal.Recipient
+	Messages     []signal.Message
+}
+
+func parseMessageTemplate(file string, tf *timeFormat) (*template.Template, error) {
+	text, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	funcs := template.FuncMap{
+		"time": func(msec int64) string {
+			return tf.format(msec, textTimeLayout)
+		},
+		"timeLayout": func(layout string, msec int64) string {
+			return tf.time(msec).Format(layout)
+		},
+		"iso8601": func(msec int64) string {
+			return tf.time(msec).Format(iso8601TimeLayout)
+		},
+		"date": func(msec int64) string {
+			return tf.time(msec).Format("2006-01-02")
+		},
+		"lines":    templateLines,
+		"indent":   templateIndent,
+		"csv":      templateEscapeCSV,
+		"json":     templateEscapeJSON,
+		"markdown": templateEscapeMarkdown,
+		"xml":      template.HTMLEscapeString,
+	}
+
+	return template.New(filepath.Base(file)).Funcs(funcs).Parse(string(text))
+}
+
+// templateExtension returns the extension of the files written with the
+// specified template. For example, for "wiki.md.tmpl", it returns ".md".
+func templateExtension(file string) string {
+	ext := filepath.Ext(strings.TrimSuffix(filepath.Base(file), ".tmpl"))
+	if ext == "" {
+		ext = ".txt"
+	}
+	return ext
+}
+
+// templateWriteMessages executes the template for the messages of a
+// conversation. If redact is true, the raw message data from the database is
+// removed, because it cannot be redacted reliably.
+func templateWriteMessages(ew *errio.Writer, tmpl *template.Template, redact bool, msgs []signal.Message) error {
+	if redact {
+		msgs = slices.Clone(msgs)
+		for i := range msgs {
+			msgs[i].JSON = ""
+		}
+	}
+
+	data := templateData{
+		Conversation: msgs[0].Conversation,
+		Messages:     msgs,
+	}
+	if err := tmpl.Execute(ew, &data); err != nil {
+		return err
+	}
+	return ew.Err()
+}
+
+func templateLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return strings.Split(s, "\n")
+}
+
+func templateIndent(prefix, s string) string {
+	return prefix + strings.ReplaceAll(s, "\n", "\n"+prefix)
+}
+
+func templateEscapeCSV(s string) string {
+	if !strings.ContainsAny(s, "\",\r\n") {
+		return s
+	}
+	return `"` + strings.ReplaceAll(s, `"`, `""`) + `"`
+}
+
+func templateEscapeJSON(s string) (string, error) {
+	b, err := json.Marshal(s)
+	return string(b), err
+}
+
+func templateEscapeMarkdown(s string) string {
+	var sb strings.Builder
+	for _, r := range s {
+		if strings.ContainsRune("\\`*_{}[]<>()#+-.!|~", r) {
+			sb.WriteByte('\\')
+		}
+		sb.WriteRune(r)
+	}
+	return sb.String()
+}
@@ -0,0 +1,65 @@
+// Copyright (c) 2026 Tim van der Molen <tim@kariliq.nl>
+//
+// Permission to use, copy, modify, and distribute this software for any
+// purpose with or without fee is hereby granted, provided that the above
+// copyright notice and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+// WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+// ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+// WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+// ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+// OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tbvdm/sigtop/errio"
+	"github.com/tbvdm/sigtop/signal"
+)
+
+func TestTemplateRedaction(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "test.tmpl")
+	text := "{{range .Messages}}{{.Body.Text}}|{{.JSON}}\n{{end}}"
+	if err := os.WriteFile(file, []byte(text), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	tmpl, err := parseMessageTemplate(file, &timeFormat{loc: time.UTC})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	conv := &signal.Recipient{Type: signal.RecipientTypeContact, Contact: signal.Contact{Name: "Contact 1"}}
+	msgs := []signal.Message{{
+		Conversation: conv,
+		Body:         signal.MessageBody{Text: "call [phone]"},
+		JSON:         `{"body":"call +31612345678","sourceServiceId":"8c9ff3a5-2b6c-4d4e-9f1a-0123456789ab"}`,
+	}}
+
+	for _, redact := range []bool{false, true} {
+		var sb strings.Builder
+		if err := templateWriteMessages(errio.NewWriter(&sb), tmpl, redact, msgs); err != nil {
+			t.Fatal(err)
+		}
+		leaked := strings.Contains(sb.String(), "+31612345678") || strings.Contains(sb.String(), "8c9ff3a5")
+		if leaked != !redact {
+			t.Errorf("redact %v: unexpected output %q", redact, sb.String())
+		}
+		if !strings.Contains(sb.String(), "call [phone]") {
+			t.Errorf("redact %v: body missing from %q", redact, sb.String())
+		}
+	}
+
+	// The messages themselves must not be changed
+	if msgs[0].JSON == "" {
+		t.Error("message data cleared")
+	}
+}
@@ -17,22 +17,31 @@ package main
 import (
 	"fmt"
 	"strings"
-	"time"
 
 	"github.com/tbvdm/sigtop/errio"
 	"github.com/tbvdm/sigtop/signal"
 )
 
-func textWriteMessages(ew *errio.Writer, msgs []signal.Message) error {
+func textWriteMessages(ew *errio.Writer, tf *timeFormat, links map[string]string, msgs []signal.Message) error {
 	textWriteRecipientField(ew, "", "Conversation", msgs[0].Conversation)
 	fmt.Fprintln(ew)
 	for _, msg := range msgs {
-		textWriteMessage(ew, &msg)
+		textWriteMessage(ew, tf, links, &msg)
 	}
 	return ew.Err()
 }
 
-func textWriteMessage(ew *errio.Writer, msg *signal.Message) {
+// textWriteTimeline writes messages from multiple conversations. The
+// conversation is written with each message.
+func textWriteTimeline(ew *errio.Writer, tf *timeFormat, links map[string]string, msgs []signal.Message) error {
+	for _, msg := range msgs {
+		textWriteRecipientField(ew, "", "Conversation", msg.Conversation)
+		textWriteMessage(ew, tf, links, &msg)
+	}
+	return ew.Err()
+}
+
+func textWriteMessage(ew *errio.Writer, tf *timeFormat, links map[string]string, msg *signal.Message) {
 	if msg.IsOutgoing() {
 		textWriteField(ew, "", "From", "You")
 	} else if msg.Source != nil {
@@ -44,21 +53,21 @@ func textWriteMessage(ew *errio.Writer, msg *signal.Message) {
 		textWriteField(ew, "", "Type", "unknown")
 	}
 	if msg.TimeSent != 0 {
-		textWriteTimeField(ew, "", "Sent", msg.TimeSent)
+		textWriteTimeField(ew, tf, "", "Sent", msg.TimeSent)
 	}
 	if !msg.IsOutgoing() {
-		textWriteTimeField(ew, "", "Received", msg.TimeRecv)
+		textWriteTimeField(ew, tf, "", "Received", msg.TimeRecv)
 	}
-	textWriteAttachmentFields(ew, "", msg.Attachments)
+	textWriteAttachmentFields(ew, "", links, msg.Attachments)
 	for _, rct := range msg.Reactions {
 		textWriteFieldf(ew, "", "Reaction", "%s from %s", rct.Emoji, rct.Recipient.DetailedDisplayName())
 	}
 	if len(msg.Edits) == 0 {
-		textWriteQuote(ew, "", msg.Quote)
+		textWriteQuote(ew, tf, "", msg.Quote)
 		textWriteBody(ew, "", &msg.Body)
 	} else {
 		textWriteFieldf(ew, "", "Edited", "%d versions", len(msg.Edits))
-		textWriteEditHistory(ew, msg.Edits)
+		textWriteEditHistory(ew, tf, links, msg.Edits)
 	}
 	fmt.Fprintln(ew)
 }
@@ -78,21 +87,25 @@ func textWriteRecipientField(ew *errio.Writer, prefix, field string, rpt *signal
 	textWriteField(ew, prefix, field, rpt.DetailedDisplayName())
 }
 
-func textWriteTimeField(ew *errio.Writer, prefix, field string, msec int64) {
+func textWriteTimeField(ew *errio.Writer, tf *timeFormat, prefix, field string, msec int64) {
 	s := "unknown"
 	if msec >= 0 {
-		s = time.UnixMilli(msec).Format("Mon, 2 Jan 2006 15:04:05 -0700")
+		s = tf.format(msec, textTimeLayout)
 	}
 	textWriteField(ew, prefix, field, s)
 }
 
-func textWriteAttachmentFields(ew *errio.Writer, prefix string, atts []signal.Attachment) {
+func textWriteAttachmentFields(ew *errio.Writer, prefix string, links map[string]string, atts []signal.Attachment) {
 	for _, att := range atts {
 		fileName := "no filename"
 		if att.FileName != "" {
 			fileName = att.FileName
 		}
-		textWriteFieldf(ew, prefix, "Attachment", "%s (%s, %d bytes)", fileName, att.ContentType, att.Size)
+		if link, ok := links[attachmentID(&att)]; ok && att.Path != "" {
+			textWriteFieldf(ew, prefix, "Attachment", "%s (%s, %d bytes) -> %s", fileName, att.ContentType, att.Size, link)
+		} else {
+			textWriteFieldf(ew, prefix, "Attachment", "%s (%s, %d bytes)", fileName, att.ContentType, att.Size)
+		}
 	}
 }
 
@@ -109,7 +122,7 @@ func textWriteBody(ew *errio.Writer, prefix string, body *signal.MessageBody) {
 	}
 }
 
-func textWriteQuote(ew *errio.Writer, prefix string, qte *signal.Quote) {
+func textWriteQuote(ew *errio.Writer, tf *timeFormat, prefix string, qte *signal.Quote) {
 	if qte == nil {
 		return
 	}
@@ -119,7 +132,7 @@ func textWriteQuote(ew *errio.Writer, prefix string, qte *signal.Quote) {
 	}
 	prefix += ">"
 	textWriteRecipientField(ew, prefix, "From", qte.Recipient)
-	textWriteTimeField(ew, prefix, "Sent", qte.TimeSent)
+	textWriteTimeField(ew, tf, prefix, "Sent", qte.TimeSent)
 	textWriteQuoteAttachmentFields(ew, prefix, qte.Attachments)
 	textWriteBody(ew, prefix, &qte.Body)
 }
@@ -134,14 +147,14 @@ func textWriteQuoteAttachmentFields(ew *errio.Writer, prefix string, atts []sign
 	}
 }
 
-func textWriteEditHistory(ew *errio.Writer, edits []signal.Edit) {
+func textWriteEditHistory(ew *errio.Writer, tf *timeFormat, links map[string]string, edits []signal.Edit) {
 	fmt.Fprintln(ew)
 	prefix := "|"
 	for i := range edits {
 		textWriteFieldf(ew, prefix, "Version", "%d", len(edits)-i)
-		textWriteAttachmentFields(ew, prefix, edits[i].Attachments)
-		textWriteTimeField(ew, prefix, "Sent", edits[i].TimeEdit)
-		textWriteQuote(ew, prefix, edits[i].Quote)
+		textWriteAttachmentFields(ew, prefix, links, edits[i].Attachments)
+		textWriteTimeField(ew, tf, prefix, "Sent", edits[i].TimeEdit)
+		textWriteQuote(ew, tf, prefix, edits[i].Quote)
 		textWriteBody(ew, prefix, &edits[i].Body)
 		if i+1 < len(edits) {
 			fmt.Fprintln(ew, prefix)
@@ -17,31 +17,43 @@ package main
 import (
 	"fmt"
 	"strings"
-	"time"
 
 	"github.com/tbvdm/sigtop/errio"
 	"github.com/tbvdm/sigtop/signal"
 )
 
-func textShortWriteMessages(ew *errio.Writer, msgs []signal.Message) error {
+func textShortWriteMessages(ew *errio.Writer, tf *timeFormat, msgs []signal.Message) error {
 	for _, msg := range msgs {
-		textShortWriteMessage(ew, &msg)
+		textShortWriteMessage(ew, tf, &msg, false)
 	}
 	return ew.Err()
 }
 
-func textShortWriteMessage(ew *errio.Writer, msg *signal.Message) {
+// textShortWriteTimeline writes messages from multiple conversations. The
+// conversation name is written on each line.
+func textShortWriteTimeline(ew *errio.Writer, tf *timeFormat, msgs []signal.Message) error {
+	for _, msg := range msgs {
+		textShortWriteMessage(ew, tf, &msg, true)
+	}
+	return ew.Err()
+}
+
+func textShortWriteMessage(ew *errio.Writer, tf *timeFormat, msg *signal.Message, withConv bool) {
 	name := "You"
 	if !msg.IsOutgoing() {
 		name = msg.Source.DisplayName()
 	}
-	fmt.Fprintf(ew, "%s %s:", textShortFormatTime(msg.TimeSent), name)
+	fmt.Fprint(ew, textShortFormatTime(tf, msg.TimeSent))
+	if withConv {
+		fmt.Fprintf(ew, " [%s]", msg.Conversation.DisplayName())
+	}
+	fmt.Fprintf(ew, " %s:", name)
 	if msg.Type != "incoming" && msg.Type != "outgoing" {
 		fmt.Fprintf(ew, " [%s message]", msg.Type)
 	} else {
 		var details []string
 		if msg.Quote != nil {
-			details = append(details, fmt.Sprintf("reply to %s on %s", msg.Quote.Recipient.DisplayName(), textShortFormatTime(msg.Quote.TimeSent)))
+			details = append(details, fmt.Sprintf("reply to %s on %s", msg.Quote.Recipient.DisplayName(), textShortFormatTime(tf, msg.Quote.TimeSent)))
 		}
 		if len(msg.Edits) > 0 {
 			details = append(details, "edited")
@@ -63,9 +75,9 @@ func textShortWriteMessage(ew *errio.Writer, msg *signal.Message) {
 	fmt.Fprintln(ew)
 }
 
-func textShortFormatTime(msec int64) string {
+func textShortFormatTime(tf *timeFormat, msec int64) string {
 	if msec < 0 {
 		return "unknown"
 	}
-	return time.UnixMilli(msec).Format("2006-01-02 15:04")
+	return tf.format(msec, textShortTimeLayout)
 }
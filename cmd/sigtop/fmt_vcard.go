@@ -0,0 +1,111 @@
+// Copyright (c) 2026 Tim van der Molen <tim@kariliq.nl>
+//
+// Permission to use, copy, modify, and distribute this software for any
+// purpose with or without fee is hereby granted, provided that the above
+// copyright notice and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+// WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+// ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+// WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+// ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+// OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+package main
+
+import (
+	"encoding/base64"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/tbvdm/sigtop/errio"
+	"github.com/tbvdm/sigtop/signal"
+)
+
+// See RFC 6350
+const vcardMaxLineLength = 75
+
+// vcardWriteContact writes a contact as a vCard 4.0. If photo is not nil, it
+// is embedded as a data URI.
+func vcardWriteContact(ew *errio.Writer, rpt *signal.Recipient, photo []byte) error {
+	c := &rpt.Contact
+
+	vcardWriteLine(ew, "BEGIN:VCARD")
+	vcardWriteLine(ew, "VERSION:4.0")
+	vcardWriteLine(ew, "FN:"+vcardEscape(rpt.DisplayName()))
+
+	if nickname := joinName(c.NicknameGivenName, c.NicknameFamilyName); nickname != "" {
+		vcardWriteLine(ew, "NICKNAME:"+vcardEscape(nickname))
+	}
+
+	profileName := c.ProfileJoinedName
+	if profileName == "" {
+		profileName = joinName(c.ProfileName, c.ProfileFamilyName)
+	}
+	if profileName != "" {
+		vcardWriteLine(ew, "X-SIGNAL-PROFILE-NAME:"+vcardEscape(profileName))
+	}
+
+	if c.Phone != "" {
+		vcardWriteLine(ew, "TEL;VALUE=uri:tel:"+c.Phone)
+	}
+
+	if c.Username != "" {
+		vcardWriteLine(ew, "X-SIGNAL-USERNAME:"+vcardEscape(c.Username))
+	}
+
+	if c.ACI != "" {
+		vcardWriteLine(ew, "UID:urn:uuid:"+strings.ToLower(c.ACI))
+	}
+
+	if c.Note != "" {
+		vcardWriteLine(ew, "NOTE:"+vcardEscape(c.Note))
+	}
+
+	if photo != nil {
+		_, typ := avatarType(photo)
+		if typ == "" {
+			typ = "application/octet-stream"
+		}
+		vcardWriteLine(ew, fmt.Sprintf("PHOTO:data:%s;base64,%s", typ, base64.StdEncoding.EncodeToString(photo)))
+	}
+
+	vcardWriteLine(ew, "END:VCARD")
+	return ew.Err()
+}
+
+func joinName(given, family string) string {
+	return strings.TrimSpace(given + " " + family)
+}
+
+// vcardEscape escapes a text value
+func vcardEscape(s string) string {
+	return strings.NewReplacer(
+		`\`, `\\`,
+		",", `\,`,
+		";", `\;`,
+		"\r\n", `\n`,
+		"\n", `\n`,
+		"\r", `\n`,
+	).Replace(s)
+}
+
+// vcardWriteLine writes a content line. Long lines are folded without
+// splitting multi-octet UTF-8 sequences.
+func vcardWriteLine(ew *errio.Writer, line string) {
+	// A continuation line starts with a space, which counts towards the
+	// maximum line length
+	limit := vcardMaxLineLength
+	for len(line) > limit {
+		n := limit
+		for n > 0 && !utf8.RuneStart(line[n]) {
+			n--
+		}
+		fmt.Fprintf(ew, "%s\r\n ", line[:n])
+		line = line[n:]
+		limit = vcardMaxLineLength - 1
+	}
+	fmt.Fprintf(ew, "%s\r\n", line)
+}
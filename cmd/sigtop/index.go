@@ -0,0 +1,132 @@
+// Copyright (c) 2026 Tim van der Molen <tim@kariliq.nl>
+//
+// Permission to use, copy, modify, and distribute this software for any
+// purpose with or without fee is hereby granted, provided that the above
+// copyright notice and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+// WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+// ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+// WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+// ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+// OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/tbvdm/sigtop/at"
+	"github.com/tbvdm/sigtop/signal"
+)
+
+// The attachment index maps attachments to the files they were exported to.
+// Each line contains an attachment ID and a slash-separated path relative to
+// the export directory, separated by a tab.
+const attachmentIndexFile = ".attachments"
+
+func attachmentID(att *signal.Attachment) string {
+	return filepath.Base(att.Path)
+}
+
+func readAttachmentIndex(d outputDir) (map[string]string, error) {
+	index := make(map[string]string)
+
+	f, err := d.Open(attachmentIndexFile)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return index, nil
+		}
+		return nil, err
+	}
+	defer f.Close()
+
+	s := bufio.NewScanner(f)
+	for s.Scan() {
+		id, path, found := strings.Cut(s.Text(), "\t")
+		if !found {
+			return nil, fmt.Errorf("%s: invalid line", attachmentIndexFile)
+		}
+		index[id] = path
+	}
+
+	return index, s.Err()
+}
+
+// writeAttachmentIndex writes the attachment index to the export directory
+// exportDir. The index is read only from directories on disk, so it is not
+// written to archives. An empty index is not written either.
+func writeAttachmentIndex(d outputDir, exportDir string, index map[string]string) error {
+	if len(index) == 0 || archiveFormatFromPath(exportDir) != archiveNone {
+		return nil
+	}
+
+	f, err := d.Create(attachmentIndexFile, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, time.Time{})
+	if err != nil {
+		return err
+	}
+
+	for id, path := range index {
+		if _, err := fmt.Fprintf(f, "%s\t%s\n", id, path); err != nil {
+			f.Discard()
+			return err
+		}
+	}
+
+	return f.Close()
+}
+
+// attachmentLinks reads the attachment index of the attachment export
+// directory attDir and returns a map from attachment IDs to links. The links
+// are relative to the message files, which are written depth directories below
+// the directory msgDir.
+func attachmentLinks(msgDir, attDir string, depth int) (map[string]string, error) {
+	absMsgDir, err := filepath.Abs(msgDir)
+	if err != nil {
+		return nil, err
+	}
+	absAttDir, err := filepath.Abs(attDir)
+	if err != nil {
+		return nil, err
+	}
+	rel, err := filepath.Rel(absMsgDir, absAttDir)
+	if err != nil {
+		return nil, err
+	}
+
+	d, err := at.Open(attDir)
+	if err != nil {
+		return nil, err
+	}
+	defer d.Close()
+
+	index, err := readAttachmentIndex(diskDir{d})
+	if err != nil {
+		return nil, err
+	}
+
+	for id, p := range index {
+		index[id] = relativeLink(depth, path.Join(filepath.ToSlash(rel), p))
+	}
+
+	return index, nil
+}
+
+// relativeLink returns a link to the slash-separated path p, which is relative
+// to a directory, from a file that is depth directories below that directory.
+func relativeLink(depth int, p string) string {
+	elems := make([]string, 0, depth+1)
+	for range depth {
+		elems = append(elems, "..")
+	}
+	return path.Join(append(elems, p)...)
+}
@@ -17,34 +17,155 @@ package main
 import (
 	"errors"
 	"fmt"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/tbvdm/sigtop/signal"
 )
 
-func parseInterval(str string) (signal.Interval, error) {
+func parseInterval(str string, loc *time.Location) (signal.Interval, error) {
+	return parseIntervalAt(str, time.Now(), loc)
+}
+
+func parseIntervalAt(str string, now time.Time, loc *time.Location) (signal.Interval, error) {
 	minStr, maxStr, found := strings.Cut(str, ",")
 	if !found {
-		maxStr = minStr
+		// A relative time on its own specifies the interval from that
+		// time onwards
+		if strings.HasPrefix(minStr, "-") {
+			maxStr = ""
+		} else {
+			maxStr = minStr
+		}
 	}
 
-	min, err := parseTime(minStr, false)
+	min, err := parseTime(minStr, false, now, loc)
 	if err != nil {
 		return signal.Interval{}, err
 	}
 
-	max, err := parseTime(maxStr, true)
+	max, err := parseTime(maxStr, true, now, loc)
 	if err != nil {
 		return signal.Interval{}, err
 	}
 
+	if !min.IsZero() && !max.IsZero() && min.After(max) {
+		return signal.Interval{}, fmt.Errorf("%s: empty interval", str)
+	}
+
 	return signal.Interval{min, max}, nil
 }
 
-func parseTime(str string, max bool) (time.Time, error) {
-	if str == "" {
+func parseTime(str string, max bool, now time.Time, loc *time.Location) (time.Time, error) {
+	switch {
+	case str == "":
 		return time.Time{}, nil
+	case str == "now":
+		return now, nil
+	case str[0] == '-':
+		return parseRelativeTime(str, now)
+	case len(str) > len("yyyy") && strings.Trim(str, "0123456789") == "":
+		// Milliseconds since the Unix epoch, as used by Signal
+		msec, err := strconv.ParseInt(str, 10, 64)
+		if err != nil {
+			return time.Time{}, invalidTimeError(str)
+		}
+		return time.UnixMilli(msec), nil
+	}
+
+	if start, end, ok := namedPeriod(str, now.In(loc)); ok {
+		if max {
+			return end.Add(-1), nil
+		}
+		return start, nil
+	}
+
+	return parseAbsoluteTime(str, max, loc)
+}
+
+func parseRelativeTime(str string, now time.Time) (time.Time, error) {
+	if len(str) < 3 {
+		return time.Time{}, invalidTimeError(str)
+	}
+
+	n, err := strconv.Atoi(str[1 : len(str)-1])
+	if err != nil || n < 0 {
+		return time.Time{}, invalidTimeError(str)
+	}
+
+	switch str[len(str)-1] {
+	case 'h':
+		return now.Add(-time.Duration(n) * time.Hour), nil
+	case 'd':
+		return now.AddDate(0, 0, -n), nil
+	case 'w':
+		return now.AddDate(0, 0, -7*n), nil
+	case 'm':
+		return now.AddDate(0, -n, 0), nil
+	case 'y':
+		return now.AddDate(-n, 0, 0), nil
+	default:
+		return time.Time{}, invalidTimeError(str)
+	}
+}
+
+// namedPeriod returns the start and end of the period with the specified
+// name. The end is exclusive.
+func namedPeriod(name string, now time.Time) (time.Time, time.Time, bool) {
+	year, month, day := now.Date()
+	today := time.Date(year, month, day, 0, 0, 0, 0, now.Location())
+	// Weeks start on Monday
+	thisWeek := today.AddDate(0, 0, -(int(today.Weekday())+6)%7)
+	thisMonth := time.Date(year, month, 1, 0, 0, 0, 0, now.Location())
+	thisYear := time.Date(year, 1, 1, 0, 0, 0, 0, now.Location())
+
+	switch name {
+	case "today":
+		return today, today.AddDate(0, 0, 1), true
+	case "yesterday":
+		return today.AddDate(0, 0, -1), today, true
+	case "this-week":
+		return thisWeek, thisWeek.AddDate(0, 0, 7), true
+	case "last-week":
+		return thisWeek.AddDate(0, 0, -7), thisWeek, true
+	case "this-month":
+		return thisMonth, thisMonth.AddDate(0, 1, 0), true
+	case "last-month":
+		return thisMonth.AddDate(0, -1, 0), thisMonth, true
+	case "this-year":
+		return thisYear, thisYear.AddDate(1, 0, 0), true
+	case "last-year":
+		return thisYear.AddDate(-1, 0, 0), thisYear, true
+	default:
+		return time.Time{}, time.Time{}, false
+	}
+}
+
+func parseAbsoluteTime(str string, max bool, loc *time.Location) (time.Time, error) {
+	orig := str
+
+	// Handle a time zone designator, as in RFC 3339
+	if strings.Contains(str, "T") {
+		if s, ok := strings.CutSuffix(str, "Z"); ok {
+			str, loc = s, time.UTC
+		} else if i := len(str) - len("+hh:mm"); i > 0 && (str[i] == '+' || str[i] == '-') && str[i+3] == ':' {
+			offset, err := time.Parse("-07:00", str[i:])
+			if err != nil {
+				return time.Time{}, invalidTimeError(orig)
+			}
+			_, secs := offset.Zone()
+			str, loc = str[:i], time.FixedZone(str[i:], secs)
+		}
+	}
+
+	// Handle fractional seconds
+	var frac string
+	if i := len("yyyy-mm-ddThh:mm:ss"); len(str) > i && str[i] == '.' {
+		str, frac = str[:i], str[i:]
+		if len(frac) < 2 || len(frac) > 10 || strings.Trim(frac[1:], "0123456789") != "" {
+			return time.Time{}, invalidTimeError(orig)
+		}
 	}
 
 	year, month, day := 0, 0, 0
@@ -64,23 +185,32 @@ func parseTime(str string, max bool) (time.Time, error) {
 	case len("yyyy-mm-ddThh:mm:ss"):
 		nsec = time.Second
 	default:
-		return time.Time{}, invalidTimeError(str)
+		return time.Time{}, invalidTimeError(orig)
 	}
 
 	layout := "2006-01-02T15:04:05"
-	t, err := time.ParseInLocation(layout[:len(str)], str, time.Local)
+	t, err := time.ParseInLocation(layout[:len(str)], str, loc)
 	if err != nil {
 		var perr *time.ParseError
 		if errors.As(err, &perr) {
 			if perr.Message == "" {
-				err = invalidTimeError(str)
+				err = invalidTimeError(orig)
 			} else {
-				err = fmt.Errorf("%s%s", str, perr.Message)
+				err = fmt.Errorf("%s%s", orig, perr.Message)
 			}
 		}
 		return t, err
 	}
 
+	if frac != "" {
+		n, _ := strconv.Atoi(frac[1:])
+		nsec = time.Second
+		for range len(frac) - 1 {
+			nsec /= 10
+		}
+		t = t.Add(time.Duration(n) * nsec)
+	}
+
 	if max {
 		t = t.AddDate(year, month, day)
 		t = t.Add(nsec)
@@ -0,0 +1,125 @@
+// Copyright (c) 2026 Tim van der Molen <tim@kariliq.nl>
+//
+// Permission to use, copy, modify, and distribute this software for any
+// purpose with or without fee is hereby granted, provided that the above
+// copyright notice and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+// WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+// ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+// WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+// ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+// OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseIntervalAt(t *testing.T) {
+	// A Wednesday
+	now := time.Date(2024, 5, 15, 12, 30, 0, 0, time.UTC)
+	date := func(year int, month time.Month, day, hour, min, sec int) time.Time {
+		return time.Date(year, month, day, hour, min, sec, 0, time.UTC)
+	}
+	var zero time.Time
+
+	tests := []struct {
+		str      string
+		min, max time.Time
+	}{
+		// Relative times
+		{"-2d", now.AddDate(0, 0, -2), zero},
+		{"-3h,now", now.Add(-3 * time.Hour), now},
+		{"-1w,-1d", now.AddDate(0, 0, -7), now.AddDate(0, 0, -1)},
+		{"-1m", now.AddDate(0, -1, 0), zero},
+		{"-1y,", now.AddDate(-1, 0, 0), zero},
+
+		// Named periods
+		{"today", date(2024, 5, 15, 0, 0, 0), date(2024, 5, 16, 0, 0, 0).Add(-1)},
+		{"yesterday", date(2024, 5, 14, 0, 0, 0), date(2024, 5, 15, 0, 0, 0).Add(-1)},
+		{"this-week", date(2024, 5, 13, 0, 0, 0), date(2024, 5, 20, 0, 0, 0).Add(-1)},
+		{"last-week", date(2024, 5, 6, 0, 0, 0), date(2024, 5, 13, 0, 0, 0).Add(-1)},
+		{"last-month", date(2024, 4, 1, 0, 0, 0), date(2024, 5, 1, 0, 0, 0).Add(-1)},
+		{"this-year,", date(2024, 1, 1, 0, 0, 0), zero},
+		{",last-year", zero, date(2024, 1, 1, 0, 0, 0).Add(-1)},
+
+		// Absolute times
+		{"2024", date(2024, 1, 1, 0, 0, 0), date(2025, 1, 1, 0, 0, 0).Add(-1)},
+		{"2024-02", date(2024, 2, 1, 0, 0, 0), date(2024, 3, 1, 0, 0, 0).Add(-1)},
+		{"2024-02-29T23", date(2024, 2, 29, 23, 0, 0), date(2024, 3, 1, 0, 0, 0).Add(-1)},
+		{"2024-01-01,2024-01-31", date(2024, 1, 1, 0, 0, 0), date(2024, 2, 1, 0, 0, 0).Add(-1)},
+		{"2024-05-01T10:00:00Z,", date(2024, 5, 1, 10, 0, 0), zero},
+		{"2024-05-01T10:00:00+02:00,", date(2024, 5, 1, 8, 0, 0), zero},
+		{"2024-05-01T10:00:00.25Z", date(2024, 5, 1, 10, 0, 0).Add(250 * time.Millisecond), date(2024, 5, 1, 10, 0, 0).Add(260 * time.Millisecond).Add(-1)},
+
+		// Milliseconds since the Unix epoch. A string of more than 4
+		// digits is never a year.
+		{"1714557600000", time.UnixMilli(1714557600000), time.UnixMilli(1714557600000)},
+		{"12345,", time.UnixMilli(12345), zero},
+		{"10000,1714557600000", time.UnixMilli(10000), time.UnixMilli(1714557600000)},
+	}
+
+	for _, test := range tests {
+		iv, err := parseIntervalAt(test.str, now, time.UTC)
+		if err != nil {
+			t.Errorf("%q: %v", test.str, err)
+			continue
+		}
+		if !iv.Min.Equal(test.min) || !iv.Max.Equal(test.max) {
+			t.Errorf("%q: got [%v, %v], want [%v, %v]", test.str, iv.Min, iv.Max, test.min, test.max)
+		}
+	}
+}
+
+func TestParseIntervalAtLocation(t *testing.T) {
+	loc := time.FixedZone("UTC+2", 2*60*60)
+	// Already the next day in loc
+	now := time.Date(2024, 5, 15, 23, 0, 0, 0, time.UTC)
+
+	iv, err := parseIntervalAt("today", now, loc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := time.Date(2024, 5, 16, 0, 0, 0, 0, loc); !iv.Min.Equal(want) {
+		t.Errorf("got %v, want %v", iv.Min, want)
+	}
+
+	iv, err = parseIntervalAt("2024-05-16,", now, loc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := time.Date(2024, 5, 15, 22, 0, 0, 0, time.UTC); !iv.Min.Equal(want) {
+		t.Errorf("got %v, want %v", iv.Min, want)
+	}
+}
+
+func TestParseIntervalAtInvalid(t *testing.T) {
+	now := time.Date(2024, 5, 15, 12, 30, 0, 0, time.UTC)
+
+	tests := []string{
+		"-d",
+		"-2x",
+		"--2d",
+		"tomorrow",
+		"24",
+		"2024-13",
+		"2024-02-30",
+		"2024-05-01T25",
+		"2024-05-01T10:00:00.",
+		"2024-05-01T10:00:00.1234567890Z",
+		"2024-05-01 10:00",
+		"99999999999999999999",
+		"2024-05-02,2024-05-01",
+		"now,-1d",
+	}
+
+	for _, str := range tests {
+		if _, err := parseIntervalAt(str, now, time.UTC); err == nil {
+			t.Errorf("%q: no error", str)
+		}
+	}
+}
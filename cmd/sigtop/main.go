@@ -16,10 +16,13 @@ package main
 
 import (
 	"bufio"
+	"errors"
+	"fmt"
 	"log"
 	"os"
 	"path/filepath"
 	"strings"
+	"time"
 
 	"github.com/tbvdm/go-cli"
 	"github.com/tbvdm/go-openbsd"
@@ -46,9 +49,13 @@ type cmdEntry struct {
 
 var cmdEntries = []cmdEntry{
 	cmdCheckDatabaseEntry,
+	cmdDecryptExportEntry,
+	cmdExportEntry,
 	cmdExportAvatarsEntry,
 	cmdExportAttachmentsEntry,
+	cmdExportContactsEntry,
 	cmdExportDatabaseEntry,
+	cmdExportGroupsEntry,
 	cmdExportKeyEntry,
 	cmdExportMessagesEntry,
 	cmdImportKeyEntry,
@@ -117,6 +124,35 @@ func encryptionKeyFromArgument(keyfile getopt.Arg) (*safestorage.RawEncryptionKe
 	return &key, nil
 }
 
+// passphraseFromArgument reads the passphrase used to encrypt or decrypt an
+// export. The passphrase is the first line of the specified file, or of
+// standard input if the file is "-".
+func passphraseFromArgument(passfile getopt.Arg) ([]byte, error) {
+	if !passfile.Set() {
+		return nil, nil
+	}
+
+	f := os.Stdin
+	if file := passfile.String(); file != "-" {
+		var err error
+		if f, err = os.Open(file); err != nil {
+			return nil, err
+		}
+		defer f.Close()
+	}
+
+	s := bufio.NewScanner(f)
+	s.Scan()
+	if s.Err() != nil {
+		return nil, s.Err()
+	}
+	if len(s.Bytes()) == 0 {
+		return nil, errors.New("empty passphrase")
+	}
+
+	return append([]byte{}, s.Bytes()...), nil
+}
+
 func signalDirFromArgument(dir getopt.Arg, beta bool) (string, error) {
 	if dir.Set() {
 		return dir.String(), nil
@@ -124,11 +160,27 @@ func signalDirFromArgument(dir getopt.Arg, beta bool) (string, error) {
 	return signal.DesktopDir(beta)
 }
 
-func intervalFromArgument(ival getopt.Arg) (signal.Interval, error) {
+func intervalFromArgument(ival getopt.Arg, loc *time.Location) (signal.Interval, error) {
 	if !ival.Set() {
 		return signal.Interval{}, nil
 	}
-	return parseInterval(ival.String())
+	return parseInterval(ival.String(), loc)
+}
+
+func messageTimeFromArgument(arg getopt.Arg) (signal.MessageTime, error) {
+	if !arg.Set() {
+		return signal.MessageTimeSent, nil
+	}
+	switch arg.String() {
+	case "sent":
+		return signal.MessageTimeSent, nil
+	case "received":
+		return signal.MessageTimeRecv, nil
+	case "server":
+		return signal.MessageTimeServer, nil
+	default:
+		return signal.MessageTimeSent, fmt.Errorf("invalid message time: %s", arg.String())
+	}
 }
 
 func filenameSanitiserFromArgument(arg getopt.Arg) (*filename.Sanitiser, error) {
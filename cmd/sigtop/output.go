@@ -0,0 +1,564 @@
+// Copyright (c) 2026 Tim van der Molen <tim@kariliq.nl>
+//
+// Permission to use, copy, modify, and distribute this software for any
+// purpose with or without fee is hereby granted, provided that the above
+// copyright notice and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+// WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+// ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+// WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+// ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+// OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+package main
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/tbvdm/go-openbsd"
+	"github.com/tbvdm/sigtop/at"
+	"github.com/tbvdm/sigtop/cryptio"
+)
+
+// An outputDir is a directory that exported files are written to. It is
+// either a directory on disk or a directory in an archive. Names are
+// slash-separated and relative to the directory.
+type outputDir interface {
+	// Close closes the directory. Closing the top-level directory of an
+	// archive completes the archive.
+	Close() error
+
+	// Mkdir creates a subdirectory. It is not an error if the
+	// subdirectory already exists.
+	Mkdir(name string) error
+
+	// OpenDir opens a subdirectory, creating it if necessary.
+	OpenDir(name string) (outputDir, error)
+
+	// Open opens a file for reading.
+	Open(name string) (io.ReadCloser, error)
+
+	// Create opens a file for writing. The flag argument is as for
+	// os.OpenFile, except that an existing file is always replaced unless
+	// os.O_EXCL is specified. If mtime is not zero, it is set as the
+	// modification time of the file.
+	Create(name string, flag int, mtime time.Time) (outputFile, error)
+
+	// Exists reports whether a file exists.
+	Exists(name string) (bool, error)
+}
+
+// An outputFile is a file that is being written to an outputDir. The file
+// appears under its name only once it has been closed successfully, so that
+// an interrupted export does not leave incomplete files behind.
+type outputFile interface {
+	io.Writer
+
+	// Close completes the file.
+	Close() error
+
+	// Discard abandons the file.
+	Discard() error
+
+	// SetXattr sets an extended attribute of the file. Attribute names are
+	// as for at.Dir.Setxattr. Extended attributes must be set before the
+	// file is written to.
+	SetXattr(name string, value []byte) error
+
+	// SetSize declares the size of the file before it is written to, so
+	// that the data can be written to a tar archive directly instead of
+	// being held until the file is closed. Exactly size bytes must then be
+	// written.
+	SetSize(size int64)
+}
+
+// Temporary files are created with this prefix
+const tempFilePrefix = ".sigtop-tmp-"
+
+type archiveFormat int
+
+const (
+	archiveNone archiveFormat = iota
+	archiveTar
+	archiveTarGzip
+	archiveZip
+)
+
+// archiveFormatFromPath returns the archive format implied by the filename
+// extension of path. Standard output, denoted by "-", receives a tar archive.
+func archiveFormatFromPath(path string) archiveFormat {
+	lpath := strings.ToLower(path)
+	switch {
+	case path == "-", strings.HasSuffix(lpath, ".tar"):
+		return archiveTar
+	case strings.HasSuffix(lpath, ".tar.gz"), strings.HasSuffix(lpath, ".tgz"):
+		return archiveTarGzip
+	case strings.HasSuffix(lpath, ".zip"):
+		return archiveZip
+	default:
+		return archiveNone
+	}
+}
+
+// openOutputDir opens the directory or archive at path. Archives are created
+// and must not exist already. If passphrase is not nil, the archive is
+// encrypted.
+func openOutputDir(path string, passphrase []byte) (outputDir, error) {
+	format := archiveFormatFromPath(path)
+	if format == archiveNone {
+		d, err := at.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		return diskDir{d}, nil
+	}
+
+	var w io.WriteCloser
+	if path == "-" {
+		w = os.Stdout
+	} else {
+		f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0666)
+		if err != nil {
+			return nil, err
+		}
+		w = f
+	}
+
+	closers := []io.Closer{w}
+	if passphrase != nil {
+		cw, err := cryptio.NewWriter(w, passphrase)
+		if err != nil {
+			w.Close()
+			return nil, err
+		}
+		w = cw
+		closers = append([]io.Closer{cw}, closers...)
+	}
+
+	return newArchive(w, format, closers), nil
+}
+
+// prepareOutputDir checks whether path can be used as an output directory. If
+// path refers to a directory on disk, the directory is created if necessary.
+func prepareOutputDir(path string, incremental, encrypted bool) error {
+	if archiveFormatFromPath(path) != archiveNone {
+		if incremental {
+			return errors.New("incremental export to an archive is not supported")
+		}
+		return nil
+	}
+	if encrypted {
+		return errors.New("encryption is supported only when exporting to an archive")
+	}
+	if err := os.Mkdir(path, 0777); err != nil && !errors.Is(err, fs.ErrExist) {
+		return err
+	}
+	return nil
+}
+
+func unveilOutputDir(path string) error {
+	if path == "-" {
+		return nil
+	}
+	return openbsd.Unveil(path, "rwc")
+}
+
+type diskDir struct {
+	d at.Dir
+}
+
+// Files on disk are written to a temporary file in the same directory, which
+// is renamed when the file is closed
+type diskFile struct {
+	*os.File
+	d     at.Dir
+	tmp   string
+	name  string
+	mtime time.Time
+}
+
+func (d diskDir) Close() error {
+	return d.d.Close()
+}
+
+func (d diskDir) Mkdir(name string) error {
+	if err := d.d.Mkdir(name, 0777); err != nil && !errors.Is(err, fs.ErrExist) {
+		return err
+	}
+	return nil
+}
+
+func (d diskDir) OpenDir(name string) (outputDir, error) {
+	if err := d.Mkdir(name); err != nil {
+		return nil, err
+	}
+	sd, err := d.d.OpenDir(name)
+	if err != nil {
+		return nil, err
+	}
+	return diskDir{sd}, nil
+}
+
+func (d diskDir) Open(name string) (io.ReadCloser, error) {
+	return d.d.OpenFile(name, os.O_RDONLY, 0)
+}
+
+func (d diskDir) Create(name string, flag int, mtime time.Time) (outputFile, error) {
+	if flag&os.O_EXCL != 0 {
+		if ok, err := d.Exists(name); err != nil {
+			return nil, err
+		} else if ok {
+			return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrExist}
+		}
+	}
+
+	var b [8]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return nil, err
+	}
+	tmp := path.Join(path.Dir(name), tempFilePrefix+hex.EncodeToString(b[:]))
+
+	f, err := d.d.OpenFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0666)
+	if err != nil {
+		return nil, err
+	}
+	return &diskFile{File: f, d: d.d, tmp: tmp, name: name, mtime: mtime}, nil
+}
+
+func (d diskDir) Exists(name string) (bool, error) {
+	if _, err := d.d.Stat(name, at.SymlinkNoFollow); err != nil {
+		if !errors.Is(err, fs.ErrNotExist) {
+			return false, err
+		}
+		return false, nil
+	}
+	return true, nil
+}
+
+func (f *diskFile) Close() error {
+	if err := f.File.Close(); err != nil {
+		f.d.Unlink(f.tmp, 0)
+		return err
+	}
+	if !f.mtime.IsZero() {
+		if err := f.d.Utimes(f.tmp, at.UtimeOmit, f.mtime, at.SymlinkNoFollow); err != nil {
+			f.d.Unlink(f.tmp, 0)
+			return err
+		}
+	}
+	if err := f.d.Rename(f.d, f.tmp, f.name); err != nil {
+		f.d.Unlink(f.tmp, 0)
+		return err
+	}
+	return nil
+}
+
+func (f *diskFile) Discard() error {
+	f.File.Close()
+	return f.d.Unlink(f.tmp, 0)
+}
+
+func (f *diskFile) SetXattr(name string, value []byte) error {
+	return at.Fsetxattr(f.File, name, value)
+}
+
+func (f *diskFile) SetSize(size int64) {
+}
+
+// stdoutFile is an outputFile that writes to standard output. Its output
+// cannot be discarded.
+type stdoutFile struct{}
+
+func (stdoutFile) Write(p []byte) (int, error) {
+	return os.Stdout.Write(p)
+}
+
+func (stdoutFile) Close() error {
+	return nil
+}
+
+func (stdoutFile) Discard() error {
+	return nil
+}
+
+func (stdoutFile) SetXattr(name string, value []byte) error {
+	return &fs.PathError{Op: "setxattr", Path: "stdout", Err: errors.ErrUnsupported}
+}
+
+func (stdoutFile) SetSize(size int64) {
+}
+
+// An archive is written sequentially, so files cannot be read, and each file
+// must be closed or discarded before the next one is created.
+type archive struct {
+	w       archiveWriter
+	closers []io.Closer
+	names   map[string]bool
+	mtime   time.Time
+}
+
+type archiveWriter interface {
+	writeDir(name string, mtime time.Time) error
+	create(name string, mtime time.Time) (io.WriteCloser, error)
+	close() error
+}
+
+type archiveDir struct {
+	a      *archive
+	prefix string
+}
+
+type archiveFile struct {
+	a    *archive
+	name string
+	f    io.WriteCloser
+}
+
+var errArchive = errors.New("operation not supported in archive")
+
+// newArchive returns the top-level directory of an archive written to w. The
+// closers are closed, in order, after the archive is completed.
+func newArchive(w io.Writer, format archiveFormat, closers []io.Closer) archiveDir {
+	a := &archive{
+		closers: closers,
+		names:   make(map[string]bool),
+		mtime:   time.Now(),
+	}
+
+	switch format {
+	case archiveZip:
+		a.w = zipWriter{zip.NewWriter(w)}
+	case archiveTarGzip:
+		gw := gzip.NewWriter(w)
+		a.closers = append([]io.Closer{gw}, a.closers...)
+		a.w = tarWriter{tar.NewWriter(gw)}
+	default:
+		a.w = tarWriter{tar.NewWriter(w)}
+	}
+
+	return archiveDir{a: a}
+}
+
+func (d archiveDir) Close() error {
+	if d.prefix != "" {
+		return nil
+	}
+	err := d.a.w.close()
+	for _, c := range d.a.closers {
+		if cerr := c.Close(); err == nil {
+			err = cerr
+		}
+	}
+	return err
+}
+
+func (d archiveDir) Mkdir(name string) error {
+	name = path.Join(d.prefix, name)
+	if d.a.names[name] {
+		return nil
+	}
+	if err := d.a.w.writeDir(name, d.a.mtime); err != nil {
+		return err
+	}
+	d.a.names[name] = true
+	return nil
+}
+
+func (d archiveDir) OpenDir(name string) (outputDir, error) {
+	if err := d.Mkdir(name); err != nil {
+		return nil, err
+	}
+	return archiveDir{a: d.a, prefix: path.Join(d.prefix, name)}, nil
+}
+
+func (d archiveDir) Open(name string) (io.ReadCloser, error) {
+	return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+}
+
+func (d archiveDir) Create(name string, flag int, mtime time.Time) (outputFile, error) {
+	fullName := path.Join(d.prefix, name)
+	if d.a.names[fullName] {
+		return nil, &fs.PathError{Op: "create", Path: name, Err: fs.ErrExist}
+	}
+	if mtime.IsZero() {
+		mtime = d.a.mtime
+	}
+	f, err := d.a.w.create(fullName, mtime)
+	if err != nil {
+		return nil, err
+	}
+	d.a.names[fullName] = true
+	return &archiveFile{a: d.a, name: fullName, f: f}, nil
+}
+
+func (d archiveDir) Exists(name string) (bool, error) {
+	return d.a.names[path.Join(d.prefix, name)], nil
+}
+
+func (f *archiveFile) Write(p []byte) (int, error) {
+	return f.f.Write(p)
+}
+
+func (f *archiveFile) Close() error {
+	return f.f.Close()
+}
+
+// Discard abandons a file. Data already written to a zip archive, or to a tar
+// archive after the size of the file was set, cannot be taken back.
+func (f *archiveFile) Discard() error {
+	if tf, ok := f.f.(*tarFile); ok && !tf.hdrWritten {
+		tf.buf.Reset()
+		delete(f.a.names, f.name)
+		return nil
+	}
+	return &fs.PathError{Op: "discard", Path: f.name, Err: errArchive}
+}
+
+// SetXattr sets an extended attribute. Only tar archives can store extended
+// attributes.
+func (f *archiveFile) SetXattr(name string, value []byte) error {
+	tf, ok := f.f.(*tarFile)
+	if !ok || tf.hdrWritten {
+		return &fs.PathError{Op: "setxattr", Path: f.name, Err: errArchive}
+	}
+	if tf.hdr.PAXRecords == nil {
+		tf.hdr.PAXRecords = make(map[string]string)
+	}
+	tf.hdr.PAXRecords["SCHILY.xattr."+name] = string(value)
+	return nil
+}
+
+type zipWriter struct {
+	zw *zip.Writer
+}
+
+type zipFile struct {
+	io.Writer
+}
+
+func (w zipWriter) writeDir(name string, mtime time.Time) error {
+	hdr := zip.FileHeader{Name: name + "/", Modified: mtime}
+	hdr.SetMode(fs.ModeDir | 0755)
+	_, err := w.zw.CreateHeader(&hdr)
+	return err
+}
+
+func (w zipWriter) create(name string, mtime time.Time) (io.WriteCloser, error) {
+	hdr := zip.FileHeader{Name: name, Method: zip.Deflate, Modified: mtime}
+	hdr.SetMode(0644)
+	fw, err := w.zw.CreateHeader(&hdr)
+	if err != nil {
+		return nil, err
+	}
+	return zipFile{fw}, nil
+}
+
+func (w zipWriter) close() error {
+	return w.zw.Close()
+}
+
+func (f zipFile) Close() error {
+	return nil
+}
+
+func (f *archiveFile) SetSize(size int64) {
+	if tf, ok := f.f.(*tarFile); ok {
+		tf.hdr.Size = size
+		tf.sized = true
+	}
+}
+
+// tar headers contain the file size. If the size of a file has been set, the
+// header is written before the data and the data is written directly.
+// Otherwise, the file is buffered in memory until it is closed.
+type tarWriter struct {
+	tw *tar.Writer
+}
+
+type tarFile struct {
+	tw         *tar.Writer
+	hdr        tar.Header
+	buf        bytes.Buffer
+	sized      bool
+	hdrWritten bool
+	written    int64
+}
+
+func (w tarWriter) writeDir(name string, mtime time.Time) error {
+	return w.tw.WriteHeader(&tar.Header{
+		Typeflag: tar.TypeDir,
+		Name:     name + "/",
+		Mode:     0755,
+		ModTime:  mtime,
+	})
+}
+
+func (w tarWriter) create(name string, mtime time.Time) (io.WriteCloser, error) {
+	return &tarFile{
+		tw: w.tw,
+		hdr: tar.Header{
+			Typeflag: tar.TypeReg,
+			Name:     name,
+			Mode:     0644,
+			ModTime:  mtime,
+		},
+	}, nil
+}
+
+func (w tarWriter) close() error {
+	return w.tw.Close()
+}
+
+func (f *tarFile) Write(p []byte) (int, error) {
+	if !f.sized {
+		return f.buf.Write(p)
+	}
+	if !f.hdrWritten {
+		if err := f.writeHeader(); err != nil {
+			return 0, err
+		}
+	}
+	n, err := f.tw.Write(p)
+	f.written += int64(n)
+	return n, err
+}
+
+func (f *tarFile) Close() error {
+	if !f.sized {
+		f.hdr.Size = int64(f.buf.Len())
+		if err := f.writeHeader(); err != nil {
+			return err
+		}
+		_, err := f.buf.WriteTo(f.tw)
+		return err
+	}
+	if !f.hdrWritten {
+		if err := f.writeHeader(); err != nil {
+			return err
+		}
+	}
+	if f.written != f.hdr.Size {
+		return fmt.Errorf("%s: wrote %d bytes instead of %d", f.hdr.Name, f.written, f.hdr.Size)
+	}
+	return nil
+}
+
+func (f *tarFile) writeHeader() error {
+	f.hdrWritten = true
+	return f.tw.WriteHeader(&f.hdr)
+}
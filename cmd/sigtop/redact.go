@@ -0,0 +1,269 @@
+// Copyright (c) 2026 Tim van der Molen <tim@kariliq.nl>
+//
+// Permission to use, copy, modify, and distribute this software for any
+// purpose with or without fee is hereby granted, provided that the above
+// copyright notice and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+// WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+// ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+// WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+// ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+// OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+package main
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/tbvdm/go-openbsd"
+	"github.com/tbvdm/sigtop/signal"
+)
+
+// This file implements pseudonymisation: the names and identifiers of all
+// recipients are replaced with pseudonyms. The pseudonyms are recorded in a
+// pseudonym file. If the file already exists, the pseudonyms in it are reused,
+// so that pseudonyms are stable across exports.
+
+var pseudonymFileHeader = []string{
+	"key",
+	"pseudonym",
+	"pseudonym_id",
+	"name",
+	"detail",
+}
+
+type pseudonym struct {
+	name   string
+	id     string
+	origin string
+	detail string
+}
+
+type pseudonymMap struct {
+	byKey    map[string]*pseudonym
+	contacts int
+	groups   int
+}
+
+func unveilPseudonymFile(file string) error {
+	if file == "" {
+		return nil
+	}
+	// The file is written to a temporary file in the same directory first
+	return openbsd.Unveil(filepath.Dir(file), "rwc")
+}
+
+// pseudonymiseRecipients replaces the names and identifiers of all recipients
+// with pseudonyms. Their avatars are removed, unless keepAvatars is true. It
+// must be called after the conversations to export have been selected, so
+// that conversation selectors match the original names.
+func pseudonymiseRecipients(ctx *signal.Context, file string, keepAvatars bool) error {
+	pm, err := readPseudonymFile(file)
+	if err != nil {
+		return err
+	}
+
+	convs, err := ctx.Conversations()
+	if err != nil {
+		return err
+	}
+
+	// Assign new pseudonyms in a stable order
+	sort.Slice(convs, func(i, j int) bool {
+		return recipientKey(convs[i].Recipient) < recipientKey(convs[j].Recipient)
+	})
+
+	for _, conv := range convs {
+		rpt := conv.Recipient
+		p, err := pm.pseudonym(rpt)
+		if err != nil {
+			return err
+		}
+		switch rpt.Type {
+		case signal.RecipientTypeContact:
+			rpt.Contact = signal.Contact{Name: p.name, ACI: p.id}
+		case signal.RecipientTypeGroup:
+			rpt.Group = signal.Group{Name: p.name, ID: p.id, Revision: rpt.Group.Revision}
+		}
+		if !keepAvatars {
+			rpt.Avatar = signal.Avatar{}
+			rpt.ProfileAvatar = signal.Avatar{}
+		}
+	}
+
+	return writePseudonymFile(file, pm)
+}
+
+// pseudonymiseGroupMembers replaces the service IDs of group members with the
+// ACIs of their pseudonyms. The service IDs of unknown members are removed. It
+// must be called after pseudonymiseRecipients.
+func pseudonymiseGroupMembers(mbrs []signal.GroupMember) {
+	for i := range mbrs {
+		if mbrs[i].Recipient != nil {
+			mbrs[i].ServiceID = mbrs[i].Recipient.Contact.ACI
+		} else {
+			mbrs[i].ServiceID = ""
+		}
+	}
+}
+
+// recipientKey returns a string that identifies a recipient across exports
+func recipientKey(rpt *signal.Recipient) string {
+	switch {
+	case rpt.Type == signal.RecipientTypeGroup:
+		return "group:" + rpt.Group.ID
+	case rpt.Contact.ACI != "":
+		return "aci:" + strings.ToLower(rpt.Contact.ACI)
+	case rpt.Contact.Phone != "":
+		return "phone:" + rpt.Contact.Phone
+	case rpt.Contact.Username != "":
+		return "username:" + rpt.Contact.Username
+	default:
+		return "name:" + rpt.DisplayName()
+	}
+}
+
+// pseudonym returns the pseudonym of a recipient, creating it if necessary
+func (pm *pseudonymMap) pseudonym(rpt *signal.Recipient) (*pseudonym, error) {
+	key := recipientKey(rpt)
+	if p, ok := pm.byKey[key]; ok {
+		return p, nil
+	}
+
+	p := &pseudonym{origin: rpt.DisplayName(), detail: rpt.Detail()}
+	var err error
+	if rpt.Type == signal.RecipientTypeGroup {
+		pm.groups++
+		p.name = "Group " + strconv.Itoa(pm.groups)
+		p.id, err = randomGroupID()
+	} else {
+		pm.contacts++
+		p.name = "Contact " + strconv.Itoa(pm.contacts)
+		p.id, err = randomACI()
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	pm.byKey[key] = p
+	return p, nil
+}
+
+// randomACI returns a random version 4 UUID
+func randomACI() (string, error) {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return "", err
+	}
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[:4], b[4:6], b[6:8], b[8:10], b[10:]), nil
+}
+
+// randomGroupID returns a random ID in the format of Signal group IDs
+func randomGroupID() (string, error) {
+	var b [32]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(b[:]), nil
+}
+
+func readPseudonymFile(file string) (*pseudonymMap, error) {
+	pm := &pseudonymMap{byKey: make(map[string]*pseudonym)}
+
+	f, err := os.Open(file)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return pm, nil
+		}
+		return nil, err
+	}
+	defer f.Close()
+
+	records, err := csv.NewReader(f).ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", file, err)
+	}
+
+	for i, rec := range records {
+		if i == 0 {
+			// Header
+			continue
+		}
+		if len(rec) != len(pseudonymFileHeader) {
+			return nil, fmt.Errorf("%s: invalid record on line %d", file, i+1)
+		}
+		p := &pseudonym{name: rec[1], id: rec[2], origin: rec[3], detail: rec[4]}
+		pm.byKey[rec[0]] = p
+		// Ensure new pseudonyms do not clash with existing ones
+		if n, ok := strings.CutPrefix(p.name, "Contact "); ok {
+			pm.contacts = max(pm.contacts, atoiOrZero(n))
+		} else if n, ok := strings.CutPrefix(p.name, "Group "); ok {
+			pm.groups = max(pm.groups, atoiOrZero(n))
+		}
+	}
+
+	return pm, nil
+}
+
+func atoiOrZero(s string) int {
+	n, err := strconv.Atoi(s)
+	if err != nil {
+		return 0
+	}
+	return n
+}
+
+// writePseudonymFile writes the pseudonym file. The file contains the
+// original names, so it is readable only by the owner. It is written to a
+// temporary file first, so that an interrupted export does not lose existing
+// pseudonyms.
+func writePseudonymFile(file string, pm *pseudonymMap) error {
+	keys := make([]string, 0, len(pm.byKey))
+	for key := range pm.byKey {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	// The temporary file is created with mode 0600
+	f, err := os.CreateTemp(filepath.Dir(file), tempFilePrefix)
+	if err != nil {
+		return err
+	}
+
+	cw := csv.NewWriter(f)
+	cw.Write(pseudonymFileHeader)
+	for _, key := range keys {
+		p := pm.byKey[key]
+		cw.Write([]string{key, p.name, p.id, p.origin, p.detail})
+	}
+	cw.Flush()
+
+	if err := cw.Error(); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+	if err := os.Rename(f.Name(), file); err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,234 @@
+// Copyright (c) 2026 Tim van der Molen <tim@kariliq.nl>
+//
+// Permission to use, copy, modify, and distribute this software for any
+// purpose with or without fee is hereby granted, provided that the above
+// copyright notice and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+// WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+// ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+// WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+// ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+// OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+package main
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/tbvdm/sigtop/errio"
+	"github.com/tbvdm/sigtop/signal"
+)
+
+// This file implements sidecar files: files written next to an exported
+// attachment that describe the message it was sent with.
+
+type sidecarFormat int
+
+const (
+	sidecarNone sidecarFormat = iota
+	sidecarJSON
+	sidecarXMP
+)
+
+// Namespace of the sigtop-specific XMP properties
+const xmpSigtopNamespace = "https://github.com/tbvdm/sigtop/ns/xmp/1.0/"
+
+type jsonSidecar struct {
+	FileName       string             `json:"fileName"`
+	ContentType    string             `json:"contentType"`
+	Conversation   string             `json:"conversation"`
+	ConversationID string             `json:"conversationId"`
+	Sender         string             `json:"sender"`
+	SenderID       string             `json:"senderId,omitempty"`
+	MessageID      string             `json:"messageId"`
+	Sent           string             `json:"sent"`
+	Received       string             `json:"received"`
+	Caption        string             `json:"caption,omitempty"`
+	Reactions      []jsonSidecarEmoji `json:"reactions,omitempty"`
+}
+
+type jsonSidecarEmoji struct {
+	Emoji  string `json:"emoji"`
+	Sender string `json:"sender"`
+	Sent   string `json:"sent"`
+}
+
+func sidecarFormatFromArgument(arg string) (sidecarFormat, error) {
+	switch arg {
+	case "json":
+		return sidecarJSON, nil
+	case "xmp":
+		return sidecarXMP, nil
+	default:
+		return sidecarNone, fmt.Errorf("invalid sidecar format: %s", arg)
+	}
+}
+
+// writeSidecar writes a sidecar file for an attachment that has been exported
+// to the file name in d. The name of the sidecar file is that of the
+// attachment with the extension of the sidecar format appended.
+func writeSidecar(d outputDir, name string, format sidecarFormat, conv *signal.Recipient, msg *signal.Message, att *signal.Attachment, tf *timeFormat, mtime time.Time) error {
+	var ext string
+	switch format {
+	case sidecarJSON:
+		ext = ".json"
+	case sidecarXMP:
+		ext = ".xmp"
+	}
+
+	f, err := d.Create(name+ext, os.O_WRONLY|os.O_CREATE|os.O_EXCL, mtime)
+	if err != nil {
+		return err
+	}
+	ew := errio.NewWriter(f)
+
+	switch format {
+	case sidecarJSON:
+		err = jsonWriteSidecar(ew, conv, msg, att, tf)
+	case sidecarXMP:
+		err = xmpWriteSidecar(ew, conv, msg, att, tf)
+	}
+
+	if err != nil {
+		f.Discard()
+		return fmt.Errorf("cannot write sidecar file for %s: %w", name, err)
+	}
+
+	return f.Close()
+}
+
+func sidecarSender(msg *signal.Message) string {
+	if msg.IsOutgoing() {
+		return "You"
+	}
+	// DisplayName returns "Unknown" if the sender is not known
+	return msg.Source.DisplayName()
+}
+
+// sidecarTime formats a time in ISO 8601 format. A custom time format is
+// ignored, because XMP and JSON sidecar files require ISO 8601 times.
+func sidecarTime(tf *timeFormat, msec int64) string {
+	if msec <= 0 {
+		return ""
+	}
+	return tf.time(msec).Format(iso8601TimeLayout)
+}
+
+func jsonWriteSidecar(ew *errio.Writer, conv *signal.Recipient, msg *signal.Message, att *signal.Attachment, tf *timeFormat) error {
+	sc := jsonSidecar{
+		FileName:       att.FileName,
+		ContentType:    att.ContentType,
+		Conversation:   conv.DisplayName(),
+		ConversationID: csvRecipientID(conv),
+		Sender:         sidecarSender(msg),
+		MessageID:      msg.ID,
+		Sent:           sidecarTime(tf, msg.TimeSent),
+		Received:       sidecarTime(tf, msg.TimeRecv),
+		Caption:        msg.Body.Text,
+	}
+	if msg.Source != nil {
+		sc.SenderID = csvRecipientID(msg.Source)
+	}
+	for _, rct := range msg.Reactions {
+		sc.Reactions = append(sc.Reactions, jsonSidecarEmoji{
+			Emoji:  rct.Emoji,
+			Sender: rct.Recipient.DisplayName(),
+			Sent:   sidecarTime(tf, rct.TimeSent),
+		})
+	}
+
+	enc := json.NewEncoder(ew)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(sc); err != nil {
+		return err
+	}
+	return ew.Err()
+}
+
+// xmpWriteSidecar writes an XMP packet. The caption, sender and time sent are
+// mapped to the standard Dublin Core, XMP and Photoshop properties that photo
+// management software understands. The remaining context is written in a
+// sigtop-specific namespace.
+func xmpWriteSidecar(ew *errio.Writer, conv *signal.Recipient, msg *signal.Message, att *signal.Attachment, tf *timeFormat) error {
+	sent := sidecarTime(tf, msg.TimeSent)
+
+	fmt.Fprintln(ew, "<?xpacket begin=\"\ufeff\" id=\"W5M0MpCehiHzreSzNTczkc9d\"?>")
+	fmt.Fprintln(ew, `<x:xmpmeta xmlns:x="adobe:ns:meta/">`)
+	fmt.Fprintln(ew, ` <rdf:RDF xmlns:rdf="http://www.w3.org/1999/02/22-rdf-syntax-ns#">`)
+	fmt.Fprintln(ew, `  <rdf:Description rdf:about=""`)
+	fmt.Fprintln(ew, `    xmlns:dc="http://purl.org/dc/elements/1.1/"`)
+	fmt.Fprintln(ew, `    xmlns:xmp="http://ns.adobe.com/xap/1.0/"`)
+	fmt.Fprintln(ew, `    xmlns:photoshop="http://ns.adobe.com/photoshop/1.0/"`)
+	fmt.Fprintf(ew, "    xmlns:sigtop=\"%s\">\n", xmpSigtopNamespace)
+
+	if msg.Body.Text != "" {
+		fmt.Fprintln(ew, "   <dc:description>")
+		fmt.Fprintln(ew, "    <rdf:Alt>")
+		fmt.Fprintf(ew, "     <rdf:li xml:lang=\"x-default\">%s</rdf:li>\n", xmpEscape(msg.Body.Text))
+		fmt.Fprintln(ew, "    </rdf:Alt>")
+		fmt.Fprintln(ew, "   </dc:description>")
+	}
+
+	fmt.Fprintln(ew, "   <dc:creator>")
+	fmt.Fprintln(ew, "    <rdf:Seq>")
+	fmt.Fprintf(ew, "     <rdf:li>%s</rdf:li>\n", xmpEscape(sidecarSender(msg)))
+	fmt.Fprintln(ew, "    </rdf:Seq>")
+	fmt.Fprintln(ew, "   </dc:creator>")
+
+	if att.ContentType != "" {
+		xmpWriteProperty(ew, "   ", "dc:format", att.ContentType)
+	}
+	if sent != "" {
+		xmpWriteProperty(ew, "   ", "xmp:CreateDate", sent)
+		xmpWriteProperty(ew, "   ", "photoshop:DateCreated", sent)
+	}
+
+	xmpWriteProperty(ew, "   ", "sigtop:Conversation", conv.DisplayName())
+	xmpWriteProperty(ew, "   ", "sigtop:MessageID", msg.ID)
+	if att.FileName != "" {
+		xmpWriteProperty(ew, "   ", "sigtop:FileName", att.FileName)
+	}
+	if recv := sidecarTime(tf, msg.TimeRecv); recv != "" {
+		xmpWriteProperty(ew, "   ", "sigtop:Received", recv)
+	}
+
+	if len(msg.Reactions) > 0 {
+		fmt.Fprintln(ew, "   <sigtop:Reactions>")
+		fmt.Fprintln(ew, "    <rdf:Bag>")
+		for _, rct := range msg.Reactions {
+			fmt.Fprintln(ew, "     <rdf:li rdf:parseType=\"Resource\">")
+			xmpWriteProperty(ew, "      ", "sigtop:Emoji", rct.Emoji)
+			xmpWriteProperty(ew, "      ", "sigtop:Sender", rct.Recipient.DisplayName())
+			if t := sidecarTime(tf, rct.TimeSent); t != "" {
+				xmpWriteProperty(ew, "      ", "sigtop:Sent", t)
+			}
+			fmt.Fprintln(ew, "     </rdf:li>")
+		}
+		fmt.Fprintln(ew, "    </rdf:Bag>")
+		fmt.Fprintln(ew, "   </sigtop:Reactions>")
+	}
+
+	fmt.Fprintln(ew, "  </rdf:Description>")
+	fmt.Fprintln(ew, " </rdf:RDF>")
+	fmt.Fprintln(ew, "</x:xmpmeta>")
+	fmt.Fprintln(ew, `<?xpacket end="r"?>`)
+
+	return ew.Err()
+}
+
+func xmpWriteProperty(ew *errio.Writer, indent, name, value string) {
+	fmt.Fprintf(ew, "%s<%s>%s</%s>\n", indent, name, xmpEscape(value), name)
+}
+
+func xmpEscape(s string) string {
+	var sb strings.Builder
+	xml.EscapeText(&sb, []byte(s))
+	return sb.String()
+}
@@ -0,0 +1,87 @@
+// Copyright (c) 2026 Tim van der Molen <tim@kariliq.nl>
+//
+// Permission to use, copy, modify, and distribute this software for any
+// purpose with or without fee is hereby granted, provided that the above
+// copyright notice and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+// WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+// ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+// WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+// ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+// OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+package main
+
+import (
+	"time"
+
+	// Windows systems usually lack a time zone database
+	_ "time/tzdata"
+
+	"github.com/tbvdm/sigtop/getopt"
+)
+
+const (
+	textTimeLayout        = "Mon, 2 Jan 2006 15:04:05 -0700"
+	textShortTimeLayout   = "2006-01-02 15:04"
+	filenameTimeLayout    = "2006-01-02-15-04-05"
+	iso8601TimeLayout     = "2006-01-02T15:04:05Z07:00"
+	iso8601MsecTimeLayout = "2006-01-02T15:04:05.000Z07:00"
+)
+
+type timeFormat struct {
+	loc    *time.Location
+	layout string
+}
+
+func timeFormatFromArguments(zone, layout getopt.Arg) (*timeFormat, error) {
+	tf := timeFormat{loc: time.Local}
+
+	if zone.Set() {
+		var err error
+		if tf.loc, err = time.LoadLocation(zone.String()); err != nil {
+			return nil, err
+		}
+	}
+
+	if layout.Set() {
+		switch layout.String() {
+		case "default":
+		case "iso8601":
+			tf.layout = iso8601TimeLayout
+		case "iso8601-ms":
+			tf.layout = iso8601MsecTimeLayout
+		default:
+			tf.layout = layout.String()
+		}
+	}
+
+	return &tf, nil
+}
+
+// format formats a time in milliseconds since the Unix epoch. If no custom
+// layout has been set, defLayout is used.
+func (tf *timeFormat) format(msec int64, defLayout string) string {
+	layout := defLayout
+	if tf.layout != "" {
+		layout = tf.layout
+	}
+	return tf.time(msec).Format(layout)
+}
+
+// iso8601 reports whether the layout is an ISO 8601 layout or the default
+// layout
+func (tf *timeFormat) iso8601() bool {
+	switch tf.layout {
+	case "", iso8601TimeLayout, iso8601MsecTimeLayout:
+		return true
+	default:
+		return false
+	}
+}
+
+func (tf *timeFormat) time(msec int64) time.Time {
+	return time.UnixMilli(msec).In(tf.loc)
+}
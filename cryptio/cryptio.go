@@ -0,0 +1,253 @@
+// Copyright (c) 2026 Tim van der Molen <tim@kariliq.nl>
+//
+// Permission to use, copy, modify, and distribute this software for any
+// purpose with or without fee is hereby granted, provided that the above
+// copyright notice and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+// WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+// ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+// WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+// ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+// OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+// Package cryptio provides a writer and a reader for streams encrypted with a
+// passphrase.
+//
+// An encrypted stream consists of a header followed by a sequence of chunks.
+// The header contains a magic string, the scrypt parameters and a random
+// salt. The key is derived from the passphrase with scrypt. Each chunk is
+// encrypted with AES-256-GCM, with the header as additional data. The nonce
+// of a chunk consists of the chunk number and a flag indicating whether it is
+// the last chunk, so that chunks cannot be reordered, dropped or truncated
+// without detection. All chunks except the last one contain exactly
+// ChunkSize bytes of plaintext.
+package cryptio
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"io"
+
+	"golang.org/x/crypto/scrypt"
+)
+
+const (
+	ChunkSize = 64 * 1024
+
+	magic      = "sigtop-encrypted\x00\x01"
+	saltSize   = 16
+	keySize    = 32
+	headerSize = len(magic) + 3 + saltSize
+
+	// scrypt parameters
+	scryptLogN = 15
+	scryptR    = 8
+	scryptP    = 1
+)
+
+var (
+	ErrFormat     = errors.New("not an encrypted stream")
+	ErrDecrypt    = errors.New("decryption failed: wrong passphrase or corrupted data")
+	ErrTruncated  = errors.New("encrypted stream is truncated")
+	ErrParameters = errors.New("unsupported encryption parameters")
+)
+
+type Writer struct {
+	w      io.Writer
+	aead   cipher.AEAD
+	header []byte
+	buf    []byte
+	seq    uint64
+	err    error
+}
+
+type Reader struct {
+	r      io.Reader
+	aead   cipher.AEAD
+	header []byte
+	buf    []byte
+	plain  []byte
+	seq    uint64
+	eof    bool
+}
+
+// NewWriter returns a writer that encrypts data with a key derived from
+// passphrase and writes it to w. The caller must call Close to write the last
+// chunk.
+func NewWriter(w io.Writer, passphrase []byte) (*Writer, error) {
+	header := make([]byte, headerSize)
+	copy(header, magic)
+	header[len(magic)] = scryptLogN
+	header[len(magic)+1] = scryptR
+	header[len(magic)+2] = scryptP
+	if _, err := rand.Read(header[len(magic)+3:]); err != nil {
+		return nil, err
+	}
+
+	aead, err := newAEAD(passphrase, header)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := w.Write(header); err != nil {
+		return nil, err
+	}
+
+	return &Writer{
+		w:      w,
+		aead:   aead,
+		header: header,
+		buf:    make([]byte, 0, ChunkSize),
+	}, nil
+}
+
+func (cw *Writer) Write(p []byte) (int, error) {
+	if cw.err != nil {
+		return 0, cw.err
+	}
+
+	n := 0
+	for len(p) > 0 {
+		m := min(len(p), ChunkSize-len(cw.buf))
+		cw.buf = append(cw.buf, p[:m]...)
+		p = p[m:]
+		n += m
+		// Only write a full chunk once more data follows, because the
+		// last chunk must be shorter than ChunkSize
+		if len(cw.buf) == ChunkSize && len(p) > 0 {
+			if cw.err = cw.writeChunk(false); cw.err != nil {
+				return n, cw.err
+			}
+		}
+	}
+
+	return n, nil
+}
+
+// Close writes the last chunk. It does not close the underlying writer.
+func (cw *Writer) Close() error {
+	if cw.err != nil {
+		return cw.err
+	}
+	if len(cw.buf) == ChunkSize {
+		if cw.err = cw.writeChunk(false); cw.err != nil {
+			return cw.err
+		}
+	}
+	cw.err = cw.writeChunk(true)
+	if cw.err == nil {
+		cw.err = errors.New("cryptio: write after close")
+		return nil
+	}
+	return cw.err
+}
+
+func (cw *Writer) writeChunk(last bool) error {
+	ct := cw.aead.Seal(nil, chunkNonce(cw.seq, last), cw.buf, cw.header)
+	cw.seq++
+	cw.buf = cw.buf[:0]
+	_, err := cw.w.Write(ct)
+	return err
+}
+
+// NewReader returns a reader that decrypts the data read from r with a key
+// derived from passphrase.
+func NewReader(r io.Reader, passphrase []byte) (*Reader, error) {
+	header := make([]byte, headerSize)
+	if _, err := io.ReadFull(r, header); err != nil {
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			return nil, ErrFormat
+		}
+		return nil, err
+	}
+	if !bytes.Equal(header[:len(magic)], []byte(magic)) {
+		return nil, ErrFormat
+	}
+
+	aead, err := newAEAD(passphrase, header)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Reader{
+		r:      r,
+		aead:   aead,
+		header: header,
+		buf:    make([]byte, ChunkSize+aead.Overhead()),
+	}, nil
+}
+
+func (cr *Reader) Read(p []byte) (int, error) {
+	for len(cr.plain) == 0 {
+		if cr.eof {
+			return 0, io.EOF
+		}
+		if err := cr.readChunk(); err != nil {
+			return 0, err
+		}
+	}
+
+	n := copy(p, cr.plain)
+	cr.plain = cr.plain[n:]
+	return n, nil
+}
+
+func (cr *Reader) readChunk() error {
+	// The last chunk is the only one that is shorter than a full chunk.
+	// If the stream ends after a full chunk, it has been truncated.
+	n, err := io.ReadFull(cr.r, cr.buf)
+	last := false
+	switch {
+	case errors.Is(err, io.EOF), errors.Is(err, io.ErrUnexpectedEOF):
+		last = true
+	case err != nil:
+		return err
+	}
+
+	if n < cr.aead.Overhead() {
+		return ErrTruncated
+	}
+
+	cr.plain, err = cr.aead.Open(cr.buf[:0], chunkNonce(cr.seq, last), cr.buf[:n], cr.header)
+	if err != nil {
+		return ErrDecrypt
+	}
+	cr.seq++
+	cr.eof = last
+	return nil
+}
+
+func newAEAD(passphrase, header []byte) (cipher.AEAD, error) {
+	params := header[len(magic):]
+	logN, r, p := int(params[0]), int(params[1]), int(params[2])
+	if logN < 10 || logN > 22 || r < 1 || r > 32 || p < 1 || p > 16 {
+		return nil, ErrParameters
+	}
+
+	key, err := scrypt.Key(passphrase, params[3:], 1<<logN, r, p, keySize)
+	if err != nil {
+		return nil, err
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return cipher.NewGCM(block)
+}
+
+func chunkNonce(seq uint64, last bool) []byte {
+	nonce := make([]byte, 12)
+	binary.BigEndian.PutUint64(nonce[3:11], seq)
+	if last {
+		nonce[11] = 1
+	}
+	return nonce
+}
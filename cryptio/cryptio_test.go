@@ -0,0 +1,82 @@
+// Copyright (c) 2026 Tim van der Molen <tim@kariliq.nl>
+//
+// Permission to use, copy, modify, and distribute this software for any
+// purpose with or without fee is hereby granted, provided that the above
+// copyright notice and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+// WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+// ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+// WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+// ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+// OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+package cryptio
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+)
+
+var passphrase = []byte("correct horse battery staple")
+
+func encrypt(t *testing.T, data []byte) []byte {
+	var buf bytes.Buffer
+	w, err := NewWriter(&buf, passphrase)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := w.Write(data); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func decrypt(data, passphrase []byte) ([]byte, error) {
+	r, err := NewReader(bytes.NewReader(data), passphrase)
+	if err != nil {
+		return nil, err
+	}
+	return io.ReadAll(r)
+}
+
+func TestRoundTrip(t *testing.T) {
+	for _, size := range []int{0, 1, ChunkSize - 1, ChunkSize, ChunkSize + 1, 3 * ChunkSize} {
+		data := bytes.Repeat([]byte("x"), size)
+		got, err := decrypt(encrypt(t, data), passphrase)
+		if err != nil {
+			t.Errorf("size %d: %v", size, err)
+		} else if !bytes.Equal(got, data) {
+			t.Errorf("size %d: data differs", size)
+		}
+	}
+}
+
+func TestWrongPassphrase(t *testing.T) {
+	enc := encrypt(t, []byte("secret"))
+	if _, err := decrypt(enc, []byte("wrong")); !errors.Is(err, ErrDecrypt) {
+		t.Errorf("got error %v, want %v", err, ErrDecrypt)
+	}
+}
+
+func TestTruncated(t *testing.T) {
+	enc := encrypt(t, bytes.Repeat([]byte("x"), 2*ChunkSize))
+	chunk := ChunkSize + 16
+	for _, n := range []int{headerSize + chunk, headerSize + chunk + 100, len(enc) - 1} {
+		if _, err := decrypt(enc[:n], passphrase); err == nil {
+			t.Errorf("truncation to %d bytes not detected", n)
+		}
+	}
+}
+
+func TestNotEncrypted(t *testing.T) {
+	if _, err := decrypt([]byte("plain text that is long enough for a header"), passphrase); !errors.Is(err, ErrFormat) {
+		t.Errorf("got error %v, want %v", err, ErrFormat)
+	}
+}
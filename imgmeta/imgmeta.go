@@ -0,0 +1,226 @@
+// Copyright (c) 2026 Tim van der Molen <tim@kariliq.nl>
+//
+// Permission to use, copy, modify, and distribute this software for any
+// purpose with or without fee is hereby granted, provided that the above
+// copyright notice and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+// WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+// ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+// WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+// ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+// OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+// Package imgmeta removes metadata, such as EXIF, XMP and IPTC data, from
+// JPEG, PNG and WebP images. Only the parts of an image that contain metadata
+// are removed; the image data itself is copied unchanged.
+package imgmeta
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+)
+
+var ErrFormat = errors.New("malformed image")
+
+var (
+	jpegMagic = []byte{0xff, 0xd8}
+	pngMagic  = []byte("\x89PNG\r\n\x1a\n")
+)
+
+// Strip returns a copy of data with its metadata removed. The image format is
+// determined from data. Data in an unsupported format is returned unchanged.
+// The returned boolean reports whether the format is supported.
+func Strip(data []byte) ([]byte, bool, error) {
+	var out []byte
+	var err error
+	switch {
+	case bytes.HasPrefix(data, jpegMagic):
+		out, err = stripJPEG(data)
+	case bytes.HasPrefix(data, pngMagic):
+		out, err = stripPNG(data)
+	case len(data) >= 12 && string(data[:4]) == "RIFF" && string(data[8:12]) == "WEBP":
+		out, err = stripWebP(data)
+	default:
+		return data, false, nil
+	}
+	return out, true, err
+}
+
+// JPEG markers
+const (
+	jpegSOI  = 0xd8
+	jpegEOI  = 0xd9
+	jpegSOS  = 0xda
+	jpegRST0 = 0xd0
+	jpegRST7 = 0xd7
+	jpegTEM  = 0x01
+	jpegAPP0 = 0xe0
+	jpegAPP2 = 0xe2
+	jpegAPPE = 0xee
+	jpegAPPF = 0xef
+	jpegCOM  = 0xfe
+)
+
+// stripJPEG removes all APPn and COM segments, except for the ones that
+// affect how the image is decoded: JFIF (APP0), ICC profiles (APP2) and Adobe
+// colour transforms (APP14). Data after the EOI marker is removed as well.
+func stripJPEG(data []byte) ([]byte, error) {
+	out := make([]byte, 0, len(data))
+	out = append(out, data[:2]...)
+	i := 2
+
+	for {
+		// Skip fill bytes
+		for i < len(data) && data[i] == 0xff && i+1 < len(data) && data[i+1] == 0xff {
+			i++
+		}
+		if i+2 > len(data) || data[i] != 0xff {
+			return nil, ErrFormat
+		}
+		marker := data[i+1]
+
+		switch {
+		case marker == jpegEOI:
+			return append(out, data[i:i+2]...), nil
+		case marker == jpegSOI, marker == jpegTEM, marker >= jpegRST0 && marker <= jpegRST7:
+			out = append(out, data[i:i+2]...)
+			i += 2
+			continue
+		}
+
+		if i+4 > len(data) {
+			return nil, ErrFormat
+		}
+		end := i + 2 + int(binary.BigEndian.Uint16(data[i+2:]))
+		if end < i+4 || end > len(data) {
+			return nil, ErrFormat
+		}
+		if keepJPEGSegment(marker, data[i+4:end]) {
+			out = append(out, data[i:end]...)
+		}
+		i = end
+
+		if marker == jpegSOS {
+			// Copy the entropy-coded data, which ends at the first
+			// marker other than a restart marker. Any 0xff byte in
+			// the data is followed by a stuffed 0x00 byte.
+			start := i
+			for ; i+1 < len(data); i++ {
+				if data[i] == 0xff && data[i+1] != 0 && (data[i+1] < jpegRST0 || data[i+1] > jpegRST7) {
+					break
+				}
+			}
+			if i+1 >= len(data) {
+				return nil, ErrFormat
+			}
+			out = append(out, data[start:i]...)
+		}
+	}
+}
+
+func keepJPEGSegment(marker byte, payload []byte) bool {
+	switch {
+	case marker == jpegAPP0:
+		return bytes.HasPrefix(payload, []byte("JFIF\x00")) || bytes.HasPrefix(payload, []byte("JFXX\x00"))
+	case marker == jpegAPP2:
+		return bytes.HasPrefix(payload, []byte("ICC_PROFILE\x00"))
+	case marker == jpegAPPE:
+		return bytes.HasPrefix(payload, []byte("Adobe"))
+	case marker >= jpegAPP0 && marker <= jpegAPPF, marker == jpegCOM:
+		return false
+	default:
+		return true
+	}
+}
+
+// PNG chunks that contain metadata
+var pngMetadataChunks = map[string]bool{
+	"eXIf": true,
+	"iTXt": true,
+	"tEXt": true,
+	"tIME": true,
+	"zTXt": true,
+}
+
+// stripPNG removes the text, time and EXIF chunks. Data after the IEND chunk
+// is removed as well.
+func stripPNG(data []byte) ([]byte, error) {
+	out := make([]byte, 0, len(data))
+	out = append(out, data[:len(pngMagic)]...)
+	i := len(pngMagic)
+
+	for {
+		// Chunk length, type, data and CRC
+		if i+12 > len(data) {
+			return nil, ErrFormat
+		}
+		size := binary.BigEndian.Uint32(data[i:])
+		typ := string(data[i+4 : i+8])
+		if uint64(size) > uint64(len(data)-i-12) {
+			return nil, ErrFormat
+		}
+		end := i + 12 + int(size)
+		if !pngMetadataChunks[typ] {
+			out = append(out, data[i:end]...)
+		}
+		i = end
+
+		if typ == "IEND" {
+			return out, nil
+		}
+	}
+}
+
+// VP8X flags
+const (
+	webpFlagXMP  = 0x04
+	webpFlagEXIF = 0x08
+)
+
+// stripWebP removes the EXIF and XMP chunks and clears the corresponding
+// flags in the VP8X chunk.
+func stripWebP(data []byte) ([]byte, error) {
+	riffSize := binary.LittleEndian.Uint32(data[4:])
+	if uint64(riffSize) < 4 || uint64(riffSize) > uint64(len(data)-8) {
+		return nil, ErrFormat
+	}
+	data = data[:8+riffSize]
+
+	out := make([]byte, 0, len(data))
+	out = append(out, data[:12]...)
+	i := 12
+
+	for i < len(data) {
+		if i+8 > len(data) {
+			return nil, ErrFormat
+		}
+		typ := string(data[i : i+4])
+		size := uint64(binary.LittleEndian.Uint32(data[i+4:]))
+		// Chunks are padded to an even size
+		padded := size + size&1
+		if padded > uint64(len(data)-i-8) {
+			return nil, ErrFormat
+		}
+		end := i + 8 + int(padded)
+
+		switch typ {
+		case "EXIF", "XMP ":
+		case "VP8X":
+			if size < 1 {
+				return nil, ErrFormat
+			}
+			n := len(out)
+			out = append(out, data[i:end]...)
+			out[n+8] &^= webpFlagEXIF | webpFlagXMP
+		default:
+			out = append(out, data[i:end]...)
+		}
+		i = end
+	}
+
+	binary.LittleEndian.PutUint32(out[4:], uint32(len(out)-8))
+	return out, nil
+}
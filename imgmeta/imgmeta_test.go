@@ -0,0 +1,225 @@
+// Copyright (c) 2026 Tim van der Molen <tim@kariliq.nl>
+//
+// Permission to use, copy, modify, and distribute this software for any
+// purpose with or without fee is hereby granted, provided that the above
+// copyright notice and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+// WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+// ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+// WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+// ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+// OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+package imgmeta
+
+import (
+	"bytes"
+	"encoding/binary"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"testing"
+)
+
+const secret = "GPS 52.3676 4.9041"
+
+func testImage() image.Image {
+	img := image.NewRGBA(image.Rect(0, 0, 16, 16))
+	for y := 0; y < 16; y++ {
+		for x := 0; x < 16; x++ {
+			img.Set(x, y, color.RGBA{uint8(x * 16), uint8(y * 16), 128, 255})
+		}
+	}
+	return img
+}
+
+func TestJPEG(t *testing.T) {
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, testImage(), nil); err != nil {
+		t.Fatal(err)
+	}
+	orig := buf.Bytes()
+
+	app1 := append([]byte{0xff, 0xe1, 0, 0}, "Exif\x00\x00"+secret...)
+	binary.BigEndian.PutUint16(app1[2:], uint16(len(app1)-2))
+	com := append([]byte{0xff, 0xfe, 0, 0}, secret...)
+	binary.BigEndian.PutUint16(com[2:], uint16(len(com)-2))
+
+	var data []byte
+	data = append(data, orig[:2]...)
+	data = append(data, app1...)
+	data = append(data, com...)
+	data = append(data, orig[2:]...)
+	data = append(data, secret...)
+
+	out, ok, err := Strip(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !ok {
+		t.Fatal("JPEG not recognised")
+	}
+	if bytes.Contains(out, []byte(secret)) {
+		t.Error("metadata not removed")
+	}
+	if !bytes.Equal(out, orig) {
+		t.Error("image data changed")
+	}
+}
+
+func pngChunk(typ, data string) []byte {
+	chunk := binary.BigEndian.AppendUint32(nil, uint32(len(data)))
+	chunk = append(chunk, typ+data...)
+	return binary.BigEndian.AppendUint32(chunk, crc32.ChecksumIEEE(chunk[4:]))
+}
+
+func TestPNG(t *testing.T) {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, testImage()); err != nil {
+		t.Fatal(err)
+	}
+	orig := buf.Bytes()
+
+	// Insert metadata chunks after the IHDR chunk
+	ihdrEnd := len(pngMagic) + 12 + 13
+	var data []byte
+	data = append(data, orig[:ihdrEnd]...)
+	data = append(data, pngChunk("tEXt", "Comment\x00"+secret)...)
+	data = append(data, pngChunk("eXIf", "MM\x00\x2a"+secret)...)
+	data = append(data, orig[ihdrEnd:]...)
+
+	if _, err := png.Decode(bytes.NewReader(data)); err != nil {
+		t.Fatal(err)
+	}
+
+	out, ok, err := Strip(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !ok {
+		t.Fatal("PNG not recognised")
+	}
+	if bytes.Contains(out, []byte(secret)) {
+		t.Error("metadata not removed")
+	}
+	if !bytes.Equal(out, orig) {
+		t.Error("image data changed")
+	}
+}
+
+func webpChunk(typ, data string) []byte {
+	chunk := append([]byte(typ), 0, 0, 0, 0)
+	binary.LittleEndian.PutUint32(chunk[4:], uint32(len(data)))
+	chunk = append(chunk, data...)
+	if len(data)%2 != 0 {
+		chunk = append(chunk, 0)
+	}
+	return chunk
+}
+
+func webpFile(chunks ...[]byte) []byte {
+	data := []byte("RIFF\x00\x00\x00\x00WEBP")
+	for _, chunk := range chunks {
+		data = append(data, chunk...)
+	}
+	binary.LittleEndian.PutUint32(data[4:], uint32(len(data)-8))
+	return data
+}
+
+func TestWebP(t *testing.T) {
+	vp8x := "\x00\x00\x00\x00\x0f\x00\x00\x0f\x00\x00"
+	bitstream := "\x2f\x0f\xc0\x0f\x00"
+
+	data := webpFile(
+		webpChunk("VP8X", string(rune(webpFlagEXIF|webpFlagXMP))+vp8x[1:]),
+		webpChunk("VP8L", bitstream),
+		webpChunk("EXIF", "MM\x00\x2a"+secret),
+		webpChunk("XMP ", "<x:xmpmeta>"+secret+"</x:xmpmeta>"),
+	)
+	want := webpFile(
+		webpChunk("VP8X", vp8x),
+		webpChunk("VP8L", bitstream),
+	)
+
+	out, ok, err := Strip(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !ok {
+		t.Fatal("WebP not recognised")
+	}
+	if !bytes.Equal(out, want) {
+		t.Errorf("got %q, want %q", out, want)
+	}
+}
+
+func TestUnsupported(t *testing.T) {
+	data := []byte("GIF89a" + secret)
+	out, ok, err := Strip(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ok {
+		t.Error("GIF recognised")
+	}
+	if !bytes.Equal(out, data) {
+		t.Error("data changed")
+	}
+}
+
+func TestTruncated(t *testing.T) {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, testImage()); err != nil {
+		t.Fatal(err)
+	}
+	data := buf.Bytes()
+
+	if _, _, err := Strip(data[:len(data)-6]); err != ErrFormat {
+		t.Errorf("got %v, want %v", err, ErrFormat)
+	}
+}
+
+func TestMalformed(t *testing.T) {
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, testImage(), nil); err != nil {
+		t.Fatal(err)
+	}
+	jpegData := buf.Bytes()
+
+	buf = bytes.Buffer{}
+	if err := png.Encode(&buf, testImage()); err != nil {
+		t.Fatal(err)
+	}
+	ihdr := buf.Bytes()[:len(pngMagic)+12+13]
+
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{"JPEG without segments", []byte("\xff\xd8")},
+		{"JPEG with truncated segment length", []byte("\xff\xd8\xff\xe1\x00")},
+		{"JPEG with invalid segment length", []byte("\xff\xd8\xff\xe1\x00\x01")},
+		{"JPEG with truncated segment", []byte("\xff\xd8\xff\xe1\x00\x10Exif")},
+		{"JPEG without marker", []byte("\xff\xd8\x00\x00")},
+		{"JPEG with unterminated scan", []byte("\xff\xd8\xff\xda\x00\x02\x12\x34")},
+		{"JPEG without EOI", jpegData[:len(jpegData)-2]},
+		{"PNG with truncated chunk header", append(bytes.Clone(pngMagic), "\x00\x00\x00"...)},
+		{"PNG with truncated IEND chunk", append(bytes.Clone(pngMagic), "\x00\x00\x00\x00IEND"...)},
+		{"PNG with oversized chunk", append(bytes.Clone(pngMagic), "\xff\xff\xff\xffIHDR\x00\x00\x00\x00"...)},
+		{"PNG without IEND", ihdr},
+		{"WebP with oversized RIFF size", []byte("RIFF\xff\x00\x00\x00WEBP")},
+		{"WebP with truncated chunk header", webpFile([]byte("VP8L\x05"))},
+		{"WebP with oversized chunk", webpFile([]byte("VP8L\xff\x00\x00\x00\x00\x00"))},
+		{"WebP with empty VP8X chunk", webpFile(webpChunk("VP8X", ""))},
+	}
+
+	for _, test := range tests {
+		if _, ok, err := Strip(test.data); !ok || err != ErrFormat {
+			t.Errorf("%s: got %v, %v, want true, %v", test.name, ok, err, ErrFormat)
+		}
+	}
+}
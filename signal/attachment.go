@@ -36,7 +36,8 @@ const (
 		"fileName, " +
 		"localKey, " +
 		"version, " +
-		"pending " +
+		"pending, " +
+		"flags " +
 		"FROM message_attachments " +
 		"WHERE messageId = ? AND editHistoryIndex = ? AND attachmentType = 'attachment'" +
 		"ORDER BY orderInMessage"
@@ -50,6 +51,7 @@ const (
 	attachmentColumnLocalKey
 	attachmentColumnVersion
 	attachmentColumnPending
+	attachmentColumnFlags
 )
 
 const (
@@ -70,15 +72,19 @@ type attachmentJSON struct {
 	ContentType string `json:"contentType"`
 	FileName    string `json:"fileName"`
 	Pending     bool   `json:"pending"`
+	Flags       int    `json:"flags"`
 	attachmentFile
 }
 
 type Attachment struct {
 	FileName    string
 	ContentType string
+	MessageID   string
+	Source      *Recipient
 	TimeSent    int64
 	TimeRecv    int64
 	Pending     bool
+	Flags       int
 	attachmentFile
 }
 
@@ -119,9 +125,12 @@ func (c *Context) attachmentsFromDatabase(msg *Message, editHistoryIndex int) ([
 		att := Attachment{
 			FileName:    stmt.ColumnText(attachmentColumnFileName),
 			ContentType: stmt.ColumnText(attachmentColumnContentType),
+			MessageID:   msg.ID,
+			Source:      msg.Source,
 			TimeSent:    msg.TimeSent,
 			TimeRecv:    msg.TimeRecv,
 			Pending:     stmt.ColumnInt(attachmentColumnPending) != 0,
+			Flags:       stmt.ColumnInt(attachmentColumnFlags),
 			attachmentFile: attachmentFile{
 				Version: stmt.ColumnInt(attachmentColumnVersion),
 				Path:    stmt.ColumnText(attachmentColumnPath),
@@ -142,9 +151,12 @@ func (c *Context) attachmentsFromJSON(msg *Message, jatts []attachmentJSON) []At
 		att := Attachment{
 			FileName:       jatt.FileName,
 			ContentType:    jatt.ContentType,
+			MessageID:      msg.ID,
+			Source:         msg.Source,
 			TimeSent:       msg.TimeSent,
 			TimeRecv:       msg.TimeRecv,
 			Pending:        jatt.Pending,
+			Flags:          jatt.Flags,
 			attachmentFile: jatt.attachmentFile,
 		}
 		atts = append(atts, att)
@@ -152,8 +164,12 @@ func (c *Context) attachmentsFromJSON(msg *Message, jatts []attachmentJSON) []At
 	return atts
 }
 
-func (c *Context) ConversationAttachments(conv *Conversation, ival Interval) ([]Attachment, error) {
-	msgs, err := c.ConversationMessages(conv, ival)
+func (a *Attachment) IsVoiceNote() bool {
+	return a.Flags&AttachmentFlagVoiceMessage != 0
+}
+
+func (c *Context) ConversationAttachments(conv *Conversation, filter MessageFilter) ([]Attachment, error) {
+	msgs, err := c.ConversationMessages(conv, filter)
 	if err != nil {
 		return nil, err
 	}
@@ -178,6 +194,25 @@ func (c *Context) WriteAttachment(att *Attachment, w io.Writer) error {
 	return nil
 }
 
+// AttachmentSize returns the size of the data that WriteAttachment writes
+func (c *Context) AttachmentSize(att *Attachment) (int64, error) {
+	if att.Pending {
+		return 0, fmt.Errorf("attachment is pending")
+	}
+	if att.Path == "" {
+		return 0, fmt.Errorf("attachment without path")
+	}
+	if att.Version < 2 {
+		fi, err := os.Stat(c.attachmentFilePath(att.Path))
+		if err != nil {
+			return 0, err
+		}
+		return fi.Size(), nil
+	}
+	// Encrypted attachments are truncated to their size after decryption
+	return att.Size, nil
+}
+
 func (c *Context) readAttachment(att *Attachment) ([]byte, error) {
 	if att.Pending {
 		return nil, fmt.Errorf("attachment is pending")
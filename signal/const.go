@@ -27,6 +27,9 @@ const (
 	// Content type of the long-text attachment of a long message
 	LongTextType = "text/x-signal-plain"
 
+	// Attachment flag for voice messages
+	AttachmentFlagVoiceMessage = 1
+
 	// Avatar for the Signal release chat
 	SignalAvatarPath = "images/profile-avatar.svg"
 )
@@ -51,6 +51,7 @@ func (c *Context) parseEditJSON(msg *Message, jmsg *messageJSON) error {
 		if edit.Body.Mentions, err = c.parseMentionJSON(jedit.Mentions); err != nil {
 			return &EditError{Index: editHistoryIndex, Err: err}
 		}
+		edit.Body.Styles = parseStyleJSON(jedit.Mentions)
 		if edit.Quote, err = c.parseQuoteJSON(jedit.Quote); err != nil {
 			return &EditError{Index: editHistoryIndex, Err: err}
 		}
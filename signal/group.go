@@ -0,0 +1,219 @@
+// Copyright (c) 2026 Tim van der Molen <tim@kariliq.nl>
+//
+// Permission to use, copy, modify, and distribute this software for any
+// purpose with or without fee is hereby granted, provided that the above
+// copyright notice and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+// WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+// ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+// WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+// ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+// OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+package signal
+
+import "cmp"
+
+// The columns of the messages table are qualified, because json_each has a
+// hidden column named "json"
+const groupCreationTimeQuery = "SELECT " +
+	"min(m.sent_at) " +
+	"FROM messages AS m " +
+	"WHERE m.conversationId = ? " +
+	"AND m.type = 'group-v2-change' " +
+	"AND EXISTS (" +
+	"SELECT 1 FROM json_each(m.json, '$.groupV2Change.details') AS d " +
+	"WHERE d.value ->> '$.type' = 'create')"
+
+// Based on ConversationAttributesType in ts/model-types.d.ts in the
+// Signal-Desktop repository
+type groupJSON struct {
+	Description       string                      `json:"description"`
+	Revision          int                         `json:"revision"`
+	Members           []groupMemberJSON           `json:"membersV2"`
+	PendingMembers    []groupPendingMemberJSON    `json:"pendingMembersV2"`
+	RequestingMembers []groupRequestingMemberJSON `json:"pendingAdminApprovalV2"`
+	BannedMembers     []groupBannedMemberJSON     `json:"bannedMembersV2"`
+}
+
+// In older databases, members have a "uuid" field instead of an "aci" or
+// "serviceId" field
+type groupMemberJSON struct {
+	ACI             string `json:"aci"`
+	UUID            string `json:"uuid"`
+	Role            int    `json:"role"`
+	JoinedAtVersion int    `json:"joinedAtVersion"`
+	ApprovedByACI   string `json:"approvedByAci"`
+}
+
+type groupPendingMemberJSON struct {
+	ServiceID     string `json:"serviceId"`
+	UUID          string `json:"uuid"`
+	Role          int    `json:"role"`
+	AddedByUserID string `json:"addedByUserId"`
+	Timestamp     int64  `json:"timestamp"`
+}
+
+type groupRequestingMemberJSON struct {
+	ACI       string `json:"aci"`
+	UUID      string `json:"uuid"`
+	Timestamp int64  `json:"timestamp"`
+}
+
+type groupBannedMemberJSON struct {
+	ServiceID string `json:"serviceId"`
+	UUID      string `json:"uuid"`
+	Timestamp int64  `json:"timestamp"`
+}
+
+type GroupMember struct {
+	// Nil if the member is not known
+	Recipient *Recipient
+	ServiceID string
+	Status    GroupMemberStatus
+	Role      GroupMemberRole
+	// For members only
+	JoinedAtRevision int
+	// The member who approved the join request of a member, or who invited
+	// a pending member
+	AddedBy *Recipient
+	// The time a pending member was invited, a requesting member requested
+	// to join, or a banned member was banned
+	Time int64
+}
+
+type GroupMemberStatus int
+
+const (
+	GroupMemberStatusMember GroupMemberStatus = iota
+	GroupMemberStatusPending
+	GroupMemberStatusRequesting
+	GroupMemberStatusBanned
+)
+
+// Based on Member.Role in protos/Groups.proto in the Signal-Desktop repository
+type GroupMemberRole int
+
+const (
+	GroupMemberRoleUnknown GroupMemberRole = iota
+	GroupMemberRoleDefault
+	GroupMemberRoleAdministrator
+)
+
+func (s GroupMemberStatus) String() string {
+	switch s {
+	case GroupMemberStatusMember:
+		return "member"
+	case GroupMemberStatusPending:
+		return "pending"
+	case GroupMemberStatusRequesting:
+		return "requesting"
+	case GroupMemberStatusBanned:
+		return "banned"
+	default:
+		return "unknown"
+	}
+}
+
+func (r GroupMemberRole) String() string {
+	switch r {
+	case GroupMemberRoleDefault:
+		return "member"
+	case GroupMemberRoleAdministrator:
+		return "administrator"
+	default:
+		return "unknown"
+	}
+}
+
+// GroupMembers returns the members of a group, followed by its pending,
+// requesting and banned members
+func (c *Context) GroupMembers(grp *Group) ([]GroupMember, error) {
+	var mbrs []GroupMember
+
+	add := func(mbr GroupMember, addedBy string) error {
+		var err error
+		if mbr.Recipient, err = c.recipientFromACI(mbr.ServiceID); err != nil {
+			return err
+		}
+		if addedBy != "" {
+			if mbr.AddedBy, err = c.recipientFromACI(addedBy); err != nil {
+				return err
+			}
+		}
+		mbrs = append(mbrs, mbr)
+		return nil
+	}
+
+	for _, m := range grp.members.Members {
+		mbr := GroupMember{
+			ServiceID:        cmp.Or(m.ACI, m.UUID),
+			Status:           GroupMemberStatusMember,
+			Role:             GroupMemberRole(m.Role),
+			JoinedAtRevision: m.JoinedAtVersion,
+		}
+		if err := add(mbr, m.ApprovedByACI); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, m := range grp.members.PendingMembers {
+		mbr := GroupMember{
+			ServiceID: cmp.Or(m.ServiceID, m.UUID),
+			Status:    GroupMemberStatusPending,
+			Role:      GroupMemberRole(m.Role),
+			Time:      m.Timestamp,
+		}
+		if err := add(mbr, m.AddedByUserID); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, m := range grp.members.RequestingMembers {
+		mbr := GroupMember{
+			ServiceID: cmp.Or(m.ACI, m.UUID),
+			Status:    GroupMemberStatusRequesting,
+			Time:      m.Timestamp,
+		}
+		if err := add(mbr, ""); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, m := range grp.members.BannedMembers {
+		mbr := GroupMember{
+			ServiceID: cmp.Or(m.ServiceID, m.UUID),
+			Status:    GroupMemberStatusBanned,
+			Time:      m.Timestamp,
+		}
+		if err := add(mbr, ""); err != nil {
+			return nil, err
+		}
+	}
+
+	return mbrs, nil
+}
+
+// GroupCreationTime returns the time a group was created, in milliseconds
+// since the Unix epoch. The time is known only if the conversation contains
+// the group creation event. Otherwise, 0 is returned.
+func (c *Context) GroupCreationTime(conv *Conversation) (int64, error) {
+	stmt, _, err := c.db.Prepare(groupCreationTimeQuery)
+	if err != nil {
+		return 0, err
+	}
+
+	if err := stmt.Bind(1, conv.ID); err != nil {
+		stmt.Finalize()
+		return 0, err
+	}
+
+	var t int64
+	if stmt.Step() {
+		t = stmt.ColumnInt64(0)
+	}
+
+	return t, stmt.Finalize()
+}
@@ -0,0 +1,141 @@
+// Copyright (c) 2026 Tim van der Molen <tim@kariliq.nl>
+//
+// Permission to use, copy, modify, and distribute this software for any
+// purpose with or without fee is hereby granted, provided that the above
+// copyright notice and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+// WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+// ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+// WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+// ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+// OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+package signal
+
+import (
+	"regexp"
+	"strings"
+)
+
+type maskPattern struct {
+	re   *regexp.Regexp
+	repl string
+	// If not nil, only matches for which valid returns true are masked
+	valid func(string) bool
+}
+
+// Patterns of personal data in message bodies. URLs are masked first, because
+// they may contain email addresses and phone numbers.
+var maskPatterns = []maskPattern{
+	{
+		re:   regexp.MustCompile(`(?i)\b(?:[a-z][a-z0-9+.-]*://|www\.)[^\s<>"]+[^\s<>".,;:!?)\]'}]`),
+		repl: "[URL]",
+	},
+	{
+		re:   regexp.MustCompile(`(?i)\b[a-z0-9._%+-]+@[a-z0-9-]+(?:\.[a-z0-9-]+)*\.[a-z]{2,}\b`),
+		repl: "[email]",
+	},
+	{
+		re:    regexp.MustCompile(`(?:\+|\(|\b)\d[\d ().-]*\d\b`),
+		repl:  "[phone]",
+		valid: isPhoneNumber,
+	},
+}
+
+// isPhoneNumber reports whether a sequence of digits and separators is likely
+// to be a phone number rather than, for example, a date. International
+// numbers must have at least 7 digits, other numbers at least 9.
+func isPhoneNumber(s string) bool {
+	n := 0
+	for _, r := range s {
+		if r >= '0' && r <= '9' {
+			n++
+		}
+	}
+	if strings.HasPrefix(s, "+") {
+		return n >= 7
+	}
+	return n >= 9
+}
+
+// SetMaskBodies specifies whether phone numbers, email addresses and URLs in
+// message bodies are to be replaced with placeholders.
+func (c *Context) SetMaskBodies(mask bool) {
+	c.maskBodies = mask
+}
+
+func (m *Message) maskBodies() {
+	m.Body.mask()
+	if m.Quote != nil {
+		m.Quote.Body.mask()
+	}
+	for i := range m.Edits {
+		m.Edits[i].Body.mask()
+		if m.Edits[i].Quote != nil {
+			m.Edits[i].Quote.Body.mask()
+		}
+	}
+}
+
+// mask replaces personal data in the text with placeholders. Mentions are not
+// changed. It must be called after insertMentions.
+func (b *MessageBody) mask() {
+	for _, p := range maskPatterns {
+		matches := p.re.FindAllStringIndex(b.Text, -1)
+		// Replace from the end, so that the remaining matches stay
+		// valid
+		for i := len(matches) - 1; i >= 0; i-- {
+			start, end := matches[i][0], matches[i][1]
+			if p.valid != nil && !p.valid(b.Text[start:end]) {
+				continue
+			}
+			if b.overlapsMention(start, end) {
+				continue
+			}
+			b.replaceText(start, end, p.repl)
+		}
+	}
+}
+
+func (b *MessageBody) overlapsMention(start, end int) bool {
+	for _, mnt := range b.Mentions {
+		if start < mnt.Start+mnt.Length && mnt.Start < end {
+			return true
+		}
+	}
+	return false
+}
+
+// replaceText replaces the text between the byte offsets start and end with
+// repl. Mentions and style ranges are updated accordingly. A range that starts
+// or ends within the replaced text is extended to include the whole
+// replacement.
+func (b *MessageBody) replaceText(start, end int, repl string) {
+	b.Text = b.Text[:start] + repl + b.Text[end:]
+
+	adjust := func(pos int, isStart bool) int {
+		switch {
+		case pos <= start:
+			return pos
+		case pos >= end:
+			return pos + len(repl) - (end - start)
+		case isStart:
+			return start
+		default:
+			return start + len(repl)
+		}
+	}
+
+	for i := range b.Mentions {
+		mnt := &b.Mentions[i]
+		s, e := adjust(mnt.Start, true), adjust(mnt.Start+mnt.Length, false)
+		mnt.Start, mnt.Length = s, e-s
+	}
+	for i := range b.Styles {
+		sty := &b.Styles[i]
+		s, e := adjust(sty.Start, true), adjust(sty.Start+sty.Length, false)
+		sty.Start, sty.Length = s, e-s
+	}
+}
@@ -0,0 +1,71 @@
+// Copyright (c) 2026 Tim van der Molen <tim@kariliq.nl>
+//
+// Permission to use, copy, modify, and distribute this software for any
+// purpose with or without fee is hereby granted, provided that the above
+// copyright notice and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+// WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+// ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+// WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+// ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+// OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+package signal
+
+import "testing"
+
+func TestMask(t *testing.T) {
+	tests := []struct {
+		text string
+		want string
+	}{
+		{"mail me at foo.bar@example.com.", "mail me at [email]."},
+		{"see https://example.com/a?b=c, or www.example.org", "see [URL], or [URL]"},
+		{"https://example.com/user@example.com", "[URL]"},
+		{"call +31 6 1234 5678 or (555) 123-4567", "call [phone] or [phone]"},
+		{"on 2024-01-15 at 10:30", "on 2024-01-15 at 10:30"},
+		{"+31 123", "+31 123"},
+	}
+
+	for _, test := range tests {
+		body := MessageBody{Text: test.text}
+		body.mask()
+		testText(t, &body, test.want)
+	}
+}
+
+func TestMaskRanges(t *testing.T) {
+	foo := "Fộo"
+	body := MessageBody{
+		Text: "@" + foo + " x@example.com *bold* y",
+		Mentions: []Mention{
+			{0, 1 + len(foo), contact(foo)},
+		},
+		Styles: []StyleRange{
+			{9, 14, StyleItalic}, // Starts within the email address
+			{21, 6, StyleBold},   // Follows the email address
+		},
+	}
+
+	body.mask()
+
+	testText(t, &body, "@"+foo+" [email] *bold* y")
+	testMention(t, &body, 0, 0, 6, foo)
+	testStyle(t, &body, 0, 7, 10, StyleItalic)
+	testStyle(t, &body, 1, 15, 6, StyleBold)
+}
+
+func TestMaskMention(t *testing.T) {
+	name := "+31 6 1234 5678"
+	body := MessageBody{
+		Text:     "@" + name,
+		Mentions: []Mention{{0, 1 + len(name), contact(name)}},
+	}
+
+	body.mask()
+
+	testText(t, &body, "@"+name)
+	testMention(t, &body, 0, 0, 1+len(name), name)
+}
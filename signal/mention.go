@@ -17,6 +17,7 @@ package signal
 import (
 	"fmt"
 	"log"
+	"slices"
 	"sort"
 	"strings"
 	"unicode/utf16"
@@ -28,8 +29,9 @@ type mentionJSON struct {
 	Length int `json:"length"`
 	// The "mentionUuid" field was renamed to "mentionAci" in database
 	// version 88
-	UUID string `json:"mentionUuid"`
-	ACI  string `json:"mentionAci"`
+	UUID  string `json:"mentionUuid"`
+	ACI   string `json:"mentionAci"`
+	Style Style  `json:"style"`
 }
 
 type Mention struct {
@@ -38,6 +40,34 @@ type Mention struct {
 	Recipient *Recipient
 }
 
+type Style int
+
+// Values from the BodyRange.Style enum in Signal's protobuf definitions
+const (
+	StyleNone Style = iota
+	StyleBold
+	StyleItalic
+	StyleSpoiler
+	StyleStrikethrough
+	StyleMonospace
+)
+
+type StyleRange struct {
+	Start  int
+	Length int
+	Style  Style
+}
+
+func parseStyleJSON(jmnts []mentionJSON) []StyleRange {
+	var stys []StyleRange
+	for _, jmnt := range jmnts {
+		if jmnt.ACI == "" && jmnt.UUID == "" && jmnt.Style != StyleNone {
+			stys = append(stys, StyleRange{Start: jmnt.Start, Length: jmnt.Length, Style: jmnt.Style})
+		}
+	}
+	return stys
+}
+
 func (c *Context) parseMentionJSON(jmnts []mentionJSON) ([]Mention, error) {
 	var mnts []Mention
 
@@ -107,8 +137,12 @@ func (e *ErrMention) Error() string {
 	return buf.String()
 }
 
+// insertMentions replaces mention placeholders with the names of the
+// mentioned recipients. The start and length values of mentions and style
+// ranges are converted from UTF-16 code unit counts to byte counts in the
+// updated text.
 func (b *MessageBody) insertMentions() error {
-	if len(b.Mentions) == 0 {
+	if len(b.Mentions) == 0 && len(b.Styles) == 0 {
 		return nil
 	}
 
@@ -118,6 +152,35 @@ func (b *MessageBody) insertMentions() error {
 	var text strings.Builder
 	var off int
 
+	// Skip invalid style ranges
+	b.Styles = slices.DeleteFunc(b.Styles, func(sty StyleRange) bool {
+		return sty.Start < 0 || sty.Length < 0 || sty.Start+sty.Length > len(text16)
+	})
+
+	// Byte offsets in the updated text of the start and end of style
+	// ranges, indexed by UTF-16 offsets in the original text. A style
+	// range that starts or ends within a mention is extended to include
+	// the whole mention.
+	startPos := make([]int, len(text16)+1)
+	endPos := make([]int, len(text16)+1)
+
+	copyText := func(end int) {
+		for off < end {
+			n := 1
+			if utf16.IsSurrogate(rune(text16[off])) && off+1 < end {
+				n = 2
+			}
+			startPos[off] = text.Len()
+			endPos[off] = text.Len()
+			text.WriteString(string(utf16.Decode(text16[off : off+n])))
+			if n == 2 {
+				startPos[off+1] = text.Len()
+				endPos[off+1] = text.Len()
+			}
+			off += n
+		}
+	}
+
 	for i := range b.Mentions {
 		mnt := &b.Mentions[i]
 
@@ -126,11 +189,19 @@ func (b *MessageBody) insertMentions() error {
 		}
 
 		// Copy text preceding mention
-		text.WriteString(string(utf16.Decode(text16[off:mnt.Start])))
-		off = mnt.Start + mnt.Length
+		copyText(mnt.Start)
 
 		repl := "@" + mnt.Recipient.DisplayName()
 
+		for j := mnt.Start; j < mnt.Start+mnt.Length; j++ {
+			startPos[j] = text.Len()
+			endPos[j] = text.Len() + len(repl)
+		}
+		if mnt.Length > 0 {
+			endPos[mnt.Start] = text.Len()
+		}
+		off = mnt.Start + mnt.Length
+
 		// Update mention. Note: the original start and length values
 		// were UTF-16 code unit counts, but the updated values are
 		// byte counts.
@@ -141,7 +212,21 @@ func (b *MessageBody) insertMentions() error {
 	}
 
 	// Copy text succeeding last mention
-	text.WriteString(string(utf16.Decode(text16[off:])))
+	copyText(len(text16))
+	startPos[len(text16)] = text.Len()
+	endPos[len(text16)] = text.Len()
+
+	for i := range b.Styles {
+		sty := &b.Styles[i]
+		start := startPos[sty.Start]
+		end := endPos[sty.Start+sty.Length]
+		if end < start {
+			end = start
+		}
+		sty.Start = start
+		sty.Length = end - start
+	}
+
 	b.Text = text.String()
 
 	return nil
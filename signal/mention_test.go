@@ -188,6 +188,70 @@ func TestOutOfBoundsMention2(t *testing.T) {
 	}
 }
 
+func TestStyles(t *testing.T) {
+	part, foo := "aàạ𝔞", "Fộo"
+	body := MessageBody{
+		Text: part + "\ufffc" + part,
+		Mentions: []Mention{
+			{5, 1, contact(foo)},
+		},
+		Styles: []StyleRange{
+			{0, 5, StyleBold},
+			{6, 5, StyleItalic},
+			{3, 3, StyleSpoiler},
+		},
+	}
+
+	if err := body.insertMentions(); err != nil {
+		t.Fatal(err)
+	}
+
+	testText(t, &body, part+"@"+foo+part)
+	testStyle(t, &body, 0, 0, 10, StyleBold)
+	testStyle(t, &body, 1, 16, 10, StyleItalic)
+	testStyle(t, &body, 2, 6, 10, StyleSpoiler)
+}
+
+func TestStylesWithoutMentions(t *testing.T) {
+	body := MessageBody{
+		Text: "𝔞bc",
+		Styles: []StyleRange{
+			{2, 1, StyleMonospace},
+		},
+	}
+
+	if err := body.insertMentions(); err != nil {
+		t.Fatal(err)
+	}
+
+	testText(t, &body, "𝔞bc")
+	testStyle(t, &body, 0, 4, 1, StyleMonospace)
+}
+
+func TestOutOfBoundsStyle(t *testing.T) {
+	body := MessageBody{
+		Text: "\ufffc x",
+		Mentions: []Mention{
+			{0, 1, contact("Foo")},
+		},
+		Styles: []StyleRange{
+			{0, 9, StyleItalic},
+			{2, 1, StyleBold},
+		},
+	}
+
+	if err := body.insertMentions(); err != nil {
+		t.Fatal(err)
+	}
+
+	testText(t, &body, "@Foo x")
+	testMention(t, &body, 0, 0, 4, "Foo")
+	if len(body.Styles) != 1 {
+		t.Fatalf("number of style ranges: want 1, have %d", len(body.Styles))
+	}
+	testStyle(t, &body, 0, 5, 1, StyleBold)
+}
+
 func contact(name string) *Recipient {
 	return &Recipient{
 		Type:    RecipientTypeContact,
@@ -212,3 +276,15 @@ func testMention(t *testing.T, body *MessageBody, idx, start, length int, name s
 		t.Fatalf("contact name of mention %d: want %q, have %q", idx, name, body.Mentions[idx].Recipient.Contact.Name)
 	}
 }
+
+func testStyle(t *testing.T, body *MessageBody, idx, start, length int, style Style) {
+	if body.Styles[idx].Start != start {
+		t.Fatalf("start of style range %d: want %d, have %d", idx, start, body.Styles[idx].Start)
+	}
+	if body.Styles[idx].Length != length {
+		t.Fatalf("length of style range %d: want %d, have %d", idx, length, body.Styles[idx].Length)
+	}
+	if body.Styles[idx].Style != style {
+		t.Fatalf("style of style range %d: want %d, have %d", idx, style, body.Styles[idx].Style)
+	}
+}
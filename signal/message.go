@@ -19,6 +19,8 @@ import (
 	"encoding/json"
 	"fmt"
 	"log"
+	"regexp"
+	"slices"
 	"strings"
 	"time"
 
@@ -26,6 +28,16 @@ import (
 )
 
 const (
+	// Expressions for the time a message was received, for database
+	// versions [8, 22], [23, 1270) and >= 1270, respectively
+	messageTimeRecv8    = "m.json ->> '$.received_at'"
+	messageTimeRecv23   = "coalesce(m.json ->> '$.received_at_ms', m.json ->> '$.received_at')"
+	messageTimeRecv1270 = "coalesce(m.received_at_ms, m.json ->> '$.received_at_ms', m.json ->> '$.received_at')"
+
+	// Expression for the server timestamp of a message. Outgoing messages
+	// do not have one, so fall back to the time the message was sent.
+	messageTimeServer = "coalesce(m.json ->> '$.serverTimestamp', m.sent_at)"
+
 	// For database versions [8, 19]
 	messageSelect8 = "SELECT " +
 		"m.id, " +
@@ -35,7 +47,8 @@ const (
 		"m.body, " +
 		"m.json, " +
 		"m.sent_at, " +
-		"m.json ->> '$.received_at' " +
+		messageTimeRecv8 + ", " +
+		messageTimeServer + " " +
 		"FROM messages AS m "
 
 	// For database versions [20, 22]
@@ -47,7 +60,8 @@ const (
 		"m.body, " +
 		"m.json, " +
 		"m.sent_at, " +
-		"m.json ->> '$.received_at' " +
+		messageTimeRecv8 + ", " +
+		messageTimeServer + " " +
 		"FROM messages AS m " +
 		"LEFT JOIN conversations AS c " +
 		"ON m.sourceUuid = c.uuid "
@@ -61,7 +75,8 @@ const (
 		"m.body, " +
 		"m.json, " +
 		"m.sent_at, " +
-		"coalesce(m.json ->> '$.received_at_ms', m.json ->> '$.received_at') " +
+		messageTimeRecv23 + ", " +
+		messageTimeServer + " " +
 		"FROM messages AS m " +
 		"LEFT JOIN conversations AS c " +
 		"ON m.sourceUuid = c.uuid "
@@ -75,7 +90,8 @@ const (
 		"m.body, " +
 		"m.json, " +
 		"m.sent_at, " +
-		"coalesce(m.json ->> '$.received_at_ms', m.json ->> '$.received_at') " +
+		messageTimeRecv23 + ", " +
+		messageTimeServer + " " +
 		"FROM messages AS m " +
 		"LEFT JOIN conversations AS c " +
 		"ON m.sourceServiceId = c.serviceId "
@@ -89,40 +105,15 @@ const (
 		"m.body, " +
 		"m.json, " +
 		"m.sent_at, " +
-		"coalesce(m.received_at_ms, m.json ->> '$.received_at_ms', m.json ->> '$.received_at') " +
+		messageTimeRecv1270 + ", " +
+		messageTimeServer + " " +
 		"FROM messages AS m " +
 		"LEFT JOIN conversations AS c " +
 		"ON m.sourceServiceId = c.serviceId "
 
-	messageWhereConversationID               = "WHERE m.conversationId = ? "
-	messageWhereConversationIDAndSentBefore  = messageWhereConversationID + "AND (m.sent_at <= ? OR m.sent_at IS NULL) "
-	messageWhereConversationIDAndSentAfter   = messageWhereConversationID + "AND m.sent_at >= ? "
-	messageWhereConversationIDAndSentBetween = messageWhereConversationID + "AND m.sent_at BETWEEN ? AND ? "
-	messageOrder                             = "ORDER BY m.received_at, m.sent_at"
-
-	messageQuery8    = messageSelect8 + messageWhereConversationID + messageOrder
-	messageQuery20   = messageSelect20 + messageWhereConversationID + messageOrder
-	messageQuery23   = messageSelect23 + messageWhereConversationID + messageOrder
-	messageQuery88   = messageSelect88 + messageWhereConversationID + messageOrder
-	messageQuery1270 = messageSelect1270 + messageWhereConversationID + messageOrder
-
-	messageQuerySentBefore8    = messageSelect8 + messageWhereConversationIDAndSentBefore + messageOrder
-	messageQuerySentBefore20   = messageSelect20 + messageWhereConversationIDAndSentBefore + messageOrder
-	messageQuerySentBefore23   = messageSelect23 + messageWhereConversationIDAndSentBefore + messageOrder
-	messageQuerySentBefore88   = messageSelect88 + messageWhereConversationIDAndSentBefore + messageOrder
-	messageQuerySentBefore1270 = messageSelect1270 + messageWhereConversationIDAndSentBefore + messageOrder
-
-	messageQuerySentAfter8    = messageSelect8 + messageWhereConversationIDAndSentAfter + messageOrder
-	messageQuerySentAfter20   = messageSelect20 + messageWhereConversationIDAndSentAfter + messageOrder
-	messageQuerySentAfter23   = messageSelect23 + messageWhereConversationIDAndSentAfter + messageOrder
-	messageQuerySentAfter88   = messageSelect88 + messageWhereConversationIDAndSentAfter + messageOrder
-	messageQuerySentAfter1270 = messageSelect1270 + messageWhereConversationIDAndSentAfter + messageOrder
-
-	messageQuerySentBetween8    = messageSelect8 + messageWhereConversationIDAndSentBetween + messageOrder
-	messageQuerySentBetween20   = messageSelect20 + messageWhereConversationIDAndSentBetween + messageOrder
-	messageQuerySentBetween23   = messageSelect23 + messageWhereConversationIDAndSentBetween + messageOrder
-	messageQuerySentBetween88   = messageSelect88 + messageWhereConversationIDAndSentBetween + messageOrder
-	messageQuerySentBetween1270 = messageSelect1270 + messageWhereConversationIDAndSentBetween + messageOrder
+	messageWhereConversationID = "WHERE m.conversationId = ? "
+	messageOrder               = "ORDER BY m.received_at, m.sent_at"
+	messageOrderTimeServer     = "ORDER BY " + messageTimeServer + ", m.received_at"
 )
 
 const (
@@ -134,6 +125,7 @@ const (
 	messageColumnJSON
 	messageColumnSentAt
 	messageColumnReceivedAtMS
+	messageColumnServerTimestamp
 )
 
 type messageJSON struct {
@@ -150,6 +142,7 @@ type Message struct {
 	Source       *Recipient
 	TimeSent     int64
 	TimeRecv     int64
+	TimeServer   int64
 	Type         string
 	Body         MessageBody
 	JSON         string
@@ -162,6 +155,7 @@ type Message struct {
 type MessageBody struct {
 	Text     string
 	Mentions []Mention
+	Styles   []StyleRange
 }
 
 type Interval struct {
@@ -169,153 +163,229 @@ type Interval struct {
 	Max time.Time
 }
 
-func (c *Context) ConversationMessages(conv *Conversation, ival Interval) ([]Message, error) {
-	switch {
-	case ival.Min.IsZero() && ival.Max.IsZero():
-		return c.allConversationMessages(conv)
-	case ival.Min.IsZero():
-		return c.conversationMessagesSentBefore(conv, ival.Max)
-	case ival.Max.IsZero():
-		return c.conversationMessagesSentAfter(conv, ival.Min)
-	default:
-		return c.conversationMessagesSentBetween(conv, ival.Min, ival.Max)
-	}
+type MessageTime int
+
+const (
+	MessageTimeSent MessageTime = iota
+	MessageTimeRecv
+	MessageTimeServer
+)
+
+type MessageDirection int
+
+const (
+	DirectionAny MessageDirection = iota
+	DirectionIncoming
+	DirectionOutgoing
+)
+
+// A MessageFilter selects messages. A message is selected only if it passes
+// every criterion that is set. A nil Senders slice selects messages from any
+// sender, but an empty one selects no messages at all. Time specifies the
+// timestamp that Interval applies to. Unless it is MessageTimeSent, messages
+// are also ordered by that timestamp.
+type MessageFilter struct {
+	Interval      Interval
+	Time          MessageTime
+	Senders       []*Recipient
+	Types         []string
+	Substrings    []string
+	Patterns      []*regexp.Regexp
+	HasAttachment bool
+	HasReaction   bool
+	Direction     MessageDirection
 }
 
-func (c *Context) allConversationMessages(conv *Conversation) ([]Message, error) {
-	var query string
-	switch {
-	case c.dbVersion >= 1270:
-		query = messageQuery1270
-	case c.dbVersion >= 88:
-		query = messageQuery88
-	case c.dbVersion >= 23:
-		query = messageQuery23
-	case c.dbVersion >= 20:
-		query = messageQuery20
-	default:
-		query = messageQuery8
+func (c *Context) ConversationMessages(conv *Conversation, filter MessageFilter) ([]Message, error) {
+	query, args, err := c.messageQuery(conv, &filter)
+	if err != nil {
+		return nil, err
 	}
 
 	stmt, _, err := c.db.Prepare(query)
 	if err != nil {
 		return nil, err
 	}
-	if err := stmt.BindText(1, conv.ID); err != nil {
-		stmt.Finalize()
+	for i, arg := range args {
+		if err := stmt.Bind(i+1, arg); err != nil {
+			stmt.Finalize()
+			return nil, err
+		}
+	}
+
+	msgs, err := c.messages(stmt)
+	if err != nil {
 		return nil, err
 	}
 
-	return c.messages(stmt)
-}
+	msgs = filter.selectMessages(msgs)
 
-func (c *Context) conversationMessagesSentBefore(conv *Conversation, max time.Time) ([]Message, error) {
-	var query string
-	switch {
-	case c.dbVersion >= 1270:
-		query = messageQuerySentBefore1270
-	case c.dbVersion >= 88:
-		query = messageQuerySentBefore88
-	case c.dbVersion >= 23:
-		query = messageQuerySentBefore23
-	case c.dbVersion >= 20:
-		query = messageQuerySentBefore20
-	default:
-		query = messageQuerySentBefore8
+	// Bodies are masked only now, so that patterns and substrings are
+	// matched against the original bodies
+	if c.maskBodies {
+		for i := range msgs {
+			msgs[i].maskBodies()
+		}
 	}
 
-	stmt, _, err := c.db.Prepare(query)
-	if err != nil {
-		return nil, err
+	return msgs, nil
+}
+
+// selectMessages returns the messages whose bodies are matched by the patterns
+// and substrings of the filter
+func (f *MessageFilter) selectMessages(msgs []Message) []Message {
+	if len(f.Patterns) == 0 && len(f.Substrings) == 0 {
+		return msgs
 	}
-	if err := stmt.BindText(1, conv.ID); err != nil {
-		stmt.Finalize()
-		return nil, err
+
+	// SQLite has no regular expression support, so match patterns here.
+	// Substrings are matched here too, because the body in the database
+	// contains placeholders instead of mentions, and because LIKE matches
+	// only ASCII letters case-insensitively. Note that mentions have been
+	// inserted into the body at this point.
+	patterns := slices.Clip(f.Patterns)
+	for _, s := range f.Substrings {
+		patterns = append(patterns, substringPattern(s))
 	}
-	if err := stmt.BindInt64(2, max.UnixMilli()); err != nil {
-		stmt.Finalize()
-		return nil, err
+
+	sel := msgs[:0]
+	for _, msg := range msgs {
+		if matchPatterns(patterns, &msg) {
+			sel = append(sel, msg)
+		}
 	}
 
-	return c.messages(stmt)
+	return sel
 }
 
-func (c *Context) conversationMessagesSentAfter(conv *Conversation, min time.Time) ([]Message, error) {
-	var query string
+func (c *Context) messageQuery(conv *Conversation, filter *MessageFilter) (string, []any, error) {
+	var b strings.Builder
+	var sourceColumn, timeRecv string
 	switch {
 	case c.dbVersion >= 1270:
-		query = messageQuerySentAfter1270
+		b.WriteString(messageSelect1270)
+		sourceColumn, timeRecv = "c.id", messageTimeRecv1270
 	case c.dbVersion >= 88:
-		query = messageQuerySentAfter88
+		b.WriteString(messageSelect88)
+		sourceColumn, timeRecv = "c.id", messageTimeRecv23
 	case c.dbVersion >= 23:
-		query = messageQuerySentAfter23
+		b.WriteString(messageSelect23)
+		sourceColumn, timeRecv = "c.id", messageTimeRecv23
 	case c.dbVersion >= 20:
-		query = messageQuerySentAfter20
+		b.WriteString(messageSelect20)
+		sourceColumn, timeRecv = "c.id", messageTimeRecv8
 	default:
-		query = messageQuerySentAfter8
+		b.WriteString(messageSelect8)
+		sourceColumn, timeRecv = "m.source", messageTimeRecv8
 	}
 
-	stmt, _, err := c.db.Prepare(query)
-	if err != nil {
-		return nil, err
+	b.WriteString(messageWhereConversationID)
+	args := []any{conv.ID}
+
+	var timeExpr, order string
+	switch filter.Time {
+	case MessageTimeSent:
+		timeExpr, order = "m.sent_at", messageOrder
+	case MessageTimeRecv:
+		timeExpr, order = timeRecv, "ORDER BY "+timeRecv+", m.sent_at"
+	case MessageTimeServer:
+		timeExpr, order = messageTimeServer, messageOrderTimeServer
+	default:
+		return "", nil, fmt.Errorf("invalid message time: %d", filter.Time)
 	}
-	if err := stmt.BindText(1, conv.ID); err != nil {
-		stmt.Finalize()
-		return nil, err
+
+	min, max := filter.Interval.Min, filter.Interval.Max
+	switch {
+	case min.IsZero() && max.IsZero():
+	case min.IsZero():
+		b.WriteString("AND (" + timeExpr + " <= ? OR " + timeExpr + " IS NULL) ")
+		args = append(args, max.UnixMilli())
+	case max.IsZero():
+		b.WriteString("AND " + timeExpr + " >= ? ")
+		args = append(args, min.UnixMilli())
+	default:
+		b.WriteString("AND " + timeExpr + " BETWEEN ? AND ? ")
+		args = append(args, min.UnixMilli(), max.UnixMilli())
 	}
-	if err := stmt.BindInt64(2, min.UnixMilli()); err != nil {
-		stmt.Finalize()
-		return nil, err
+
+	if filter.Senders != nil {
+		ids, err := c.conversationIDsFromRecipients(filter.Senders)
+		if err != nil {
+			return "", nil, err
+		}
+		b.WriteString("AND " + sourceColumn + " IN (" + placeholders(len(ids)) + ") ")
+		for _, id := range ids {
+			args = append(args, id)
+		}
 	}
 
-	return c.messages(stmt)
-}
+	if len(filter.Types) > 0 {
+		b.WriteString("AND m.type IN (" + placeholders(len(filter.Types)) + ") ")
+		for _, t := range filter.Types {
+			args = append(args, t)
+		}
+	}
 
-func (c *Context) conversationMessagesSentBetween(conv *Conversation, min, max time.Time) ([]Message, error) {
-	var query string
-	switch {
-	case c.dbVersion >= 1270:
-		query = messageQuerySentBetween1270
-	case c.dbVersion >= 88:
-		query = messageQuerySentBetween88
-	case c.dbVersion >= 23:
-		query = messageQuerySentBetween23
-	case c.dbVersion >= 20:
-		query = messageQuerySentBetween20
-	default:
-		query = messageQuerySentBetween8
+	switch filter.Direction {
+	case DirectionIncoming:
+		b.WriteString("AND m.type = 'incoming' ")
+	case DirectionOutgoing:
+		b.WriteString("AND m.type = 'outgoing' ")
 	}
 
-	stmt, _, err := c.db.Prepare(query)
-	if err != nil {
-		return nil, err
+	if filter.HasAttachment {
+		if c.dbVersion >= 1360 {
+			b.WriteString("AND (json_array_length(m.json, '$.attachments') > 0 OR EXISTS (" +
+				"SELECT 1 FROM message_attachments AS a " +
+				"WHERE a.messageId = m.id AND a.editHistoryIndex = -1 AND a.attachmentType = 'attachment')) ")
+		} else {
+			b.WriteString("AND json_array_length(m.json, '$.attachments') > 0 ")
+		}
 	}
-	if err := stmt.BindText(1, conv.ID); err != nil {
-		stmt.Finalize()
-		return nil, err
+
+	if filter.HasReaction {
+		b.WriteString("AND json_array_length(m.json, '$.reactions') > 0 ")
 	}
-	if err := stmt.BindInt64(2, min.UnixMilli()); err != nil {
-		stmt.Finalize()
-		return nil, err
+
+	b.WriteString(order)
+	return b.String(), args, nil
+}
+
+func matchPatterns(patterns []*regexp.Regexp, msg *Message) bool {
+	for _, re := range patterns {
+		if !re.MatchString(msg.Body.Text) {
+			return false
+		}
 	}
-	if err := stmt.BindInt64(3, max.UnixMilli()); err != nil {
-		stmt.Finalize()
-		return nil, err
+	return true
+}
+
+// placeholders returns a comma-separated list of n parameter placeholders. If
+// n is 0, it returns NULL, so that an IN operator matches nothing.
+func placeholders(n int) string {
+	if n == 0 {
+		return "NULL"
 	}
+	return strings.Repeat("?, ", n-1) + "?"
+}
 
-	return c.messages(stmt)
+// substringPattern returns a regular expression that matches s
+// case-insensitively
+func substringPattern(s string) *regexp.Regexp {
+	return regexp.MustCompile("(?i)" + regexp.QuoteMeta(s))
 }
 
 func (c *Context) messages(stmt *sqlcipher.Stmt) ([]Message, error) {
 	var msgs []Message
 	for stmt.Step() {
 		msg := Message{
-			ID:       stmt.ColumnText(messageColumnID),
-			TimeSent: stmt.ColumnInt64(messageColumnSentAt),
-			TimeRecv: stmt.ColumnInt64(messageColumnReceivedAtMS),
-			Type:     stmt.ColumnText(messageColumnType),
-			Body:     MessageBody{Text: stmt.ColumnText(messageColumnBody)},
-			JSON:     stmt.ColumnText(messageColumnJSON),
+			ID:         stmt.ColumnText(messageColumnID),
+			TimeSent:   stmt.ColumnInt64(messageColumnSentAt),
+			TimeRecv:   stmt.ColumnInt64(messageColumnReceivedAtMS),
+			TimeServer: stmt.ColumnInt64(messageColumnServerTimestamp),
+			Type:       stmt.ColumnText(messageColumnType),
+			Body:       MessageBody{Text: stmt.ColumnText(messageColumnBody)},
+			JSON:       stmt.ColumnText(messageColumnJSON),
 		}
 
 		if stmt.ColumnType(messageColumnConversationID) == sqlcipher.ColumnTypeNull {
@@ -362,12 +432,14 @@ func (c *Context) messages(stmt *sqlcipher.Stmt) ([]Message, error) {
 		if err := msg.Body.insertMentions(); err != nil {
 			msg.logError(err, "message with invalid mention")
 			msg.Body.Mentions = nil
+			msg.Body.Styles = nil
 		}
 
 		if msg.Quote != nil {
 			if err := msg.Quote.Body.insertMentions(); err != nil {
 				msg.logError(err, "message with invalid mention in quote")
 				msg.Quote.Body.Mentions = nil
+				msg.Quote.Body.Styles = nil
 			}
 		}
 
@@ -375,11 +447,13 @@ func (c *Context) messages(stmt *sqlcipher.Stmt) ([]Message, error) {
 			if err := msg.Edits[i].Body.insertMentions(); err != nil {
 				msg.logError(err, "message with invalid mention in edit %d", i)
 				msg.Edits[i].Body.Mentions = nil
+				msg.Edits[i].Body.Styles = nil
 			}
 			if msg.Edits[i].Quote != nil {
 				if err := msg.Edits[i].Quote.Body.insertMentions(); err != nil {
 					msg.logError(err, "message with invalid mention in quote in edit %d", i)
 					msg.Edits[i].Quote.Body.Mentions = nil
+					msg.Edits[i].Quote.Body.Styles = nil
 				}
 			}
 		}
@@ -399,6 +473,7 @@ func (c *Context) parseMessageJSON(msg *Message) (messageJSON, error) {
 	if msg.Body.Mentions, err = c.parseMentionJSON(jmsg.Mentions); err != nil {
 		return jmsg, err
 	}
+	msg.Body.Styles = parseStyleJSON(jmsg.Mentions)
 	if msg.Quote, err = c.parseQuoteJSON(jmsg.Quote); err != nil {
 		return jmsg, err
 	}
@@ -433,6 +508,18 @@ func (m *Message) IsOutgoing() bool {
 	return m.Type == "outgoing"
 }
 
+// Time returns the specified time of the message.
+func (m *Message) Time(t MessageTime) int64 {
+	switch t {
+	case MessageTimeRecv:
+		return m.TimeRecv
+	case MessageTimeServer:
+		return m.TimeServer
+	default:
+		return m.TimeSent
+	}
+}
+
 type MessageError struct {
 	Conversation *Recipient
 	TimeSent     int64
@@ -0,0 +1,81 @@
+// Copyright (c) 2026 Tim van der Molen <tim@kariliq.nl>
+//
+// Permission to use, copy, modify, and distribute this software for any
+// purpose with or without fee is hereby granted, provided that the above
+// copyright notice and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+// WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+// ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+// WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+// ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+// OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+package signal
+
+import (
+	"regexp"
+	"testing"
+)
+
+func TestSubstringPattern(t *testing.T) {
+	tests := []struct {
+		substr string
+		text   string
+		match  bool
+	}{
+		{"foo", "a FOO b", true},
+		{"ÄÖÜ", "xäöüx", true},
+		{"σ", "ΟΔΟΣ", true},
+		{"a.c", "abc", false},
+		{"a.c", "xA.Cx", true},
+		{"100%", "100% sure", true},
+		{"a_b", "axb", false},
+		{"@Foo", "hi @foo", true},
+		{"bar", "foo", false},
+	}
+
+	for _, test := range tests {
+		if match := substringPattern(test.substr).MatchString(test.text); match != test.match {
+			t.Errorf("substring %q, text %q: got %v, want %v", test.substr, test.text, match, test.match)
+		}
+	}
+}
+
+func TestSubstringPatternWithMention(t *testing.T) {
+	body := MessageBody{
+		Text:     "hi \ufffc",
+		Mentions: []Mention{{3, 1, contact("Foo")}},
+	}
+	if err := body.insertMentions(); err != nil {
+		t.Fatal(err)
+	}
+
+	if !substringPattern("@foo").MatchString(body.Text) {
+		t.Errorf("mention not matched in %q", body.Text)
+	}
+}
+
+func TestSelectMessagesBeforeMasking(t *testing.T) {
+	msgs := []Message{
+		{Body: MessageBody{Text: "mail alice@example.com"}},
+		{Body: MessageBody{Text: "see https://example.com/x"}},
+		{Body: MessageBody{Text: "nothing"}},
+	}
+
+	filter := MessageFilter{
+		Substrings: []string{"example.com"},
+		Patterns:   []*regexp.Regexp{regexp.MustCompile(`(?i)alice@|https://`)},
+	}
+	sel := filter.selectMessages(msgs)
+	if len(sel) != 2 {
+		t.Fatalf("got %d messages, want 2", len(sel))
+	}
+
+	for i := range sel {
+		sel[i].maskBodies()
+	}
+	testText(t, &sel[0].Body, "mail [email]")
+	testText(t, &sel[1].Body, "see [URL]")
+}
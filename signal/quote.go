@@ -91,6 +91,7 @@ func (c *Context) parseQuoteJSON(jqte *quoteJSON) (*Quote, error) {
 	if qte.Body.Mentions, err = c.parseMentionJSON(jqte.Mentions); err != nil {
 		return nil, err
 	}
+	qte.Body.Styles = parseStyleJSON(jqte.Mentions)
 
 	for _, jatt := range jqte.Attachments {
 		// Skip long-message attachments
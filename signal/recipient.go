@@ -19,6 +19,7 @@ import (
 	"encoding/hex"
 	"encoding/json"
 	"fmt"
+	"slices"
 	"strings"
 
 	"github.com/tbvdm/sigtop/sqlcipher"
@@ -90,9 +91,13 @@ type Avatar struct {
 // Based on ConversationAttributesType in ts/model-types.d.ts in the
 // Signal-Desktop repository
 type recipientJSON struct {
-	Username      string `json:"username"`
-	ProfileAvatar Avatar `json:"profileAvatar"`
-	Avatar        Avatar `json:"avatar"`
+	groupJSON
+	Username           string `json:"username"`
+	NicknameGivenName  string `json:"nicknameGivenName"`
+	NicknameFamilyName string `json:"nicknameFamilyName"`
+	Note               string `json:"note"`
+	ProfileAvatar      Avatar `json:"profileAvatar"`
+	Avatar             Avatar `json:"avatar"`
 }
 
 type Recipient struct {
@@ -118,11 +123,18 @@ type Contact struct {
 	ProfileJoinedName string
 	Phone             string
 	Username          string
+	// Set by the user
+	NicknameGivenName  string
+	NicknameFamilyName string
+	Note               string
 }
 
 type Group struct {
-	ID   string
-	Name string
+	ID          string
+	Name        string
+	Description string
+	Revision    int
+	members     groupJSON
 }
 
 func (c *Context) makeRecipientMaps() error {
@@ -173,21 +185,27 @@ func (c *Context) addRecipient(stmt *sqlcipher.Stmt) error {
 		r = &Recipient{
 			Type: RecipientTypeContact,
 			Contact: Contact{
-				ACI:               stmt.ColumnText(recipientColumnServiceID),
-				Name:              trimBidiChars(stmt.ColumnText(recipientColumnName)),
-				ProfileName:       stmt.ColumnText(recipientColumnProfileName),
-				ProfileFamilyName: stmt.ColumnText(recipientColumnProfileFamilyName),
-				ProfileJoinedName: stmt.ColumnText(recipientColumnProfileFullName),
-				Phone:             stmt.ColumnText(recipientColumnE164),
-				Username:          jrpt.Username,
+				ACI:                stmt.ColumnText(recipientColumnServiceID),
+				Name:               trimBidiChars(stmt.ColumnText(recipientColumnName)),
+				ProfileName:        stmt.ColumnText(recipientColumnProfileName),
+				ProfileFamilyName:  stmt.ColumnText(recipientColumnProfileFamilyName),
+				ProfileJoinedName:  stmt.ColumnText(recipientColumnProfileFullName),
+				Phone:              stmt.ColumnText(recipientColumnE164),
+				Username:           jrpt.Username,
+				NicknameGivenName:  jrpt.NicknameGivenName,
+				NicknameFamilyName: jrpt.NicknameFamilyName,
+				Note:               jrpt.Note,
 			},
 		}
 	case "group":
 		r = &Recipient{
 			Type: RecipientTypeGroup,
 			Group: Group{
-				ID:   stmt.ColumnText(recipientColumnGroupID),
-				Name: stmt.ColumnText(recipientColumnName),
+				ID:          stmt.ColumnText(recipientColumnGroupID),
+				Name:        stmt.ColumnText(recipientColumnName),
+				Description: jrpt.Description,
+				Revision:    jrpt.Revision,
+				members:     jrpt.groupJSON,
 			},
 		}
 	default:
@@ -249,6 +267,19 @@ func (c *Context) recipientFromACI(aci string) (*Recipient, error) {
 	return c.recipientsByACI[strings.ToLower(aci)], nil
 }
 
+func (c *Context) conversationIDsFromRecipients(rpts []*Recipient) ([]string, error) {
+	if err := c.makeRecipientMaps(); err != nil {
+		return nil, err
+	}
+	var ids []string
+	for id, rpt := range c.recipientsByConversationID {
+		if slices.Contains(rpts, rpt) {
+			ids = append(ids, id)
+		}
+	}
+	return ids, nil
+}
+
 func (r *Recipient) displayNameAndDetail() (string, string) {
 	name, detail := "Unknown", ""
 	if r != nil {
@@ -275,36 +306,65 @@ func (r *Recipient) displayNameAndDetail() (string, string) {
 			case r.Group.Name != "":
 				name = r.Group.Name
 			}
-			// Newer group IDs are 32 bytes long and
-			// base64-encoded, older ones are raw byte strings
-			id, err := base64.StdEncoding.DecodeString(r.Group.ID)
-			if err == nil && len(id) == 32 {
-				// Convert to base64url without padding
-				detail = strings.Map(func(r rune) rune {
-					switch r {
-					case '+':
-						return '-'
-					case '/':
-						return '_'
-					case '=':
-						return -1
-					default:
-						return r
-					}
-				}, r.Group.ID)
+			if _, ok := r.Group.rawID(); ok {
+				detail = r.Group.IDBase64URL()
 			} else {
-				detail = hex.EncodeToString([]byte(r.Group.ID))
+				detail = r.Group.IDHex()
 			}
 		}
 	}
 	return name, detail
 }
 
+// rawID returns the group ID as a byte string. Newer group IDs are 32 bytes
+// long and base64-encoded, older ones are raw byte strings. The second return
+// value reports whether the group ID is of the newer kind.
+func (g *Group) rawID() ([]byte, bool) {
+	id, err := base64.StdEncoding.DecodeString(g.ID)
+	if err == nil && len(id) == 32 {
+		return id, true
+	}
+	return []byte(g.ID), false
+}
+
+// IDBase64URL returns the group ID in base64url encoding without padding
+func (g *Group) IDBase64URL() string {
+	id, ok := g.rawID()
+	if !ok {
+		return base64.RawURLEncoding.EncodeToString(id)
+	}
+	return strings.Map(func(r rune) rune {
+		switch r {
+		case '+':
+			return '-'
+		case '/':
+			return '_'
+		case '=':
+			return -1
+		default:
+			return r
+		}
+	}, g.ID)
+}
+
+// IDHex returns the group ID in hexadecimal encoding
+func (g *Group) IDHex() string {
+	id, _ := g.rawID()
+	return hex.EncodeToString(id)
+}
+
 func (r *Recipient) DisplayName() string {
 	name, _ := r.displayNameAndDetail()
 	return name
 }
 
+// Detail returns the phone number, username or ACI of a contact, or the ID of
+// a group.
+func (r *Recipient) Detail() string {
+	_, detail := r.displayNameAndDetail()
+	return detail
+}
+
 func (r *Recipient) DetailedDisplayName() string {
 	name, detail := r.displayNameAndDetail()
 	if detail == "" {